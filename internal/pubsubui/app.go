@@ -18,10 +18,13 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
 	"github.com/go-chi/chi/v5"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
@@ -29,12 +32,80 @@ import (
 
 const AppName = "pubsubui"
 
+var configPollInterval = time.Second * 5
+
+func readTopics(configFilePath string) (Topics, error) {
+	rdr, err := os.Open(configFilePath)
+	if err != nil {
+		return Topics{}, errors.Wrapf(err, "could not open config file location %q", configFilePath)
+	}
+	defer rdr.Close()
+
+	topics, err := parseTopics(rdr)
+	if err != nil {
+		return Topics{}, errors.Wrap(err, "could not parse topics config")
+	}
+
+	err = topics.loadSchemaDefinitions(filepath.Dir(configFilePath))
+	if err != nil {
+		return Topics{}, errors.Wrap(err, "could not load schemas config")
+	}
+
+	err = topics.loadProtobufDescriptors(filepath.Dir(configFilePath))
+	if err != nil {
+		return Topics{}, errors.Wrap(err, "could not load protobuf config")
+	}
+
+	return topics, nil
+}
+
+// applyTopics creates, reconciles or only plans the schemas and topics in the config file, depending on the configured
+// mode.
+func applyTopics(
+	ctx context.Context,
+	cfg *config,
+	clients map[string]*pubsub.Client,
+	schemaClients map[string]*pubsub.SchemaClient,
+	topics Topics,
+) error {
+	if cfg.plan {
+		p, err := planTopics(ctx, clients, schemaClients, topics, cfg.reconcile, cfg.prune)
+		if err != nil {
+			return errors.Wrap(err, "could not plan Pub/Sub topics")
+		}
+
+		p.log()
+
+		return nil
+	}
+
+	err := createSchemas(ctx, schemaClients, topics)
+	if err != nil {
+		return errors.Wrap(err, "could not create Pub/Sub schemas")
+	}
+
+	if cfg.reconcile {
+		err = reconcileTopics(ctx, clients, topics, cfg.prune)
+		if err != nil {
+			return errors.Wrap(err, "could not reconcile Pub/Sub topics")
+		}
+	} else {
+		err = createTopics(ctx, clients, topics)
+		if err != nil {
+			return errors.Wrap(err, "could not create Pub/Sub topics")
+		}
+	}
+
+	return nil
+}
+
 func doAppSetup(
 	ctx context.Context,
-	projectIDs []string,
-	configFilePath string,
+	cfg *config,
 	projectsCh chan<- []string,
 	clientsCh chan<- map[string]*pubsub.Client,
+	schemaClientsCh chan<- map[string]*pubsub.SchemaClient,
+	subscriberClientsCh chan<- map[string]*vkit.SubscriberClient,
 	topicsCh chan<- Topics,
 	topicsCreatedCh chan<- struct{},
 ) error {
@@ -43,35 +114,28 @@ func doAppSetup(
 	skipTopicCreation := false
 
 	var topics Topics
-	if configFilePath == "" {
+	if cfg.configFilePath == "" {
 		skipTopicCreation = true
 		logWithPrefix("setup: no config file path provided, skipping topic creation")
 	} else {
-		rdr, err := os.Open(configFilePath)
-		if err != nil {
-			return errors.Wrapf(err, "setup: could not open config file location %q", configFilePath)
-		}
-
-		parsedTopics, err := parseTopics(rdr)
+		parsedTopics, err := readTopics(cfg.configFilePath)
 		if err != nil {
-			rdr.Close()
-			return errors.Wrap(err, "setup: could not parse topics config")
+			return errors.Wrap(err, "setup")
 		}
-		rdr.Close()
 
 		topics = parsedTopics
 	}
 
 	topicsCh <- topics
 
-	allProjectIDs := deduplicateStrings(append(projectIDs, topics.ProjectIDs()...))
+	allProjectIDs := deduplicateStrings(append(cfg.projectIDs, topics.ProjectIDs()...))
 	if len(allProjectIDs) == 0 {
 		return errors.New("setup: no GCP projects configured")
 	}
 
 	projectsCh <- allProjectIDs
 
-	logWithPrefix("setup: supporting the following Google Cloud Platform projects: %s", strings.Join(projectIDs, ", "))
+	logWithPrefix("setup: supporting the following Google Cloud Platform projects: %s", strings.Join(allProjectIDs, ", "))
 
 	clients, err := createClients(ctx, allProjectIDs)
 	if err != nil {
@@ -80,10 +144,24 @@ func doAppSetup(
 
 	clientsCh <- clients
 
+	schemaClients, err := createSchemaClients(ctx, allProjectIDs)
+	if err != nil {
+		return errors.Wrap(err, "setup: could not create Pub/Sub schema clients")
+	}
+
+	schemaClientsCh <- schemaClients
+
+	subscriberClients, err := createSubscriberClients(ctx, allProjectIDs)
+	if err != nil {
+		return errors.Wrap(err, "setup: could not create Pub/Sub subscriber clients")
+	}
+
+	subscriberClientsCh <- subscriberClients
+
 	if !skipTopicCreation {
-		err = createTopics(ctx, clients, topics)
+		err = applyTopics(ctx, cfg, clients, schemaClients, topics)
 		if err != nil {
-			return errors.Wrap(err, "setup: could not create Pub/Sub topics")
+			return errors.Wrap(err, "setup")
 		}
 	}
 
@@ -94,6 +172,127 @@ func doAppSetup(
 	return nil
 }
 
+// reloadConfig parses the config file again, creates clients for projects which were not configured before, applies
+// the topics and finally hands everything to the server. Clients of projects which are no longer configured are kept,
+// since streams might still be using them.
+func reloadConfig(ctx context.Context, cfg *config, srv *Server) error {
+	logWithPrefix("reload: starting")
+
+	topics, err := readTopics(cfg.configFilePath)
+	if err != nil {
+		return errors.Wrap(err, "reload")
+	}
+
+	allProjectIDs := deduplicateStrings(append(cfg.projectIDs, topics.ProjectIDs()...))
+
+	currentClients := srv.allClients()
+	clients := make(map[string]*pubsub.Client, len(allProjectIDs))
+	newProjectIDs := make([]string, 0)
+	for projectID, client := range currentClients {
+		clients[projectID] = client
+	}
+	for _, projectID := range allProjectIDs {
+		if _, ok := clients[projectID]; !ok {
+			newProjectIDs = append(newProjectIDs, projectID)
+		}
+	}
+
+	newClients, err := createClients(ctx, newProjectIDs)
+	if err != nil {
+		return errors.Wrap(err, "reload: could not create Pub/Sub clients")
+	}
+	for projectID, client := range newClients {
+		clients[projectID] = client
+	}
+
+	schemaClients := make(map[string]*pubsub.SchemaClient, len(allProjectIDs))
+	for projectID, schemaClient := range srv.allSchemaClients() {
+		schemaClients[projectID] = schemaClient
+	}
+
+	newSchemaClients, err := createSchemaClients(ctx, newProjectIDs)
+	if err != nil {
+		return errors.Wrap(err, "reload: could not create Pub/Sub schema clients")
+	}
+	for projectID, schemaClient := range newSchemaClients {
+		schemaClients[projectID] = schemaClient
+	}
+
+	subscriberClients := make(map[string]*vkit.SubscriberClient, len(allProjectIDs))
+	for projectID, subscriberClient := range srv.allSubscriberClients() {
+		subscriberClients[projectID] = subscriberClient
+	}
+
+	newSubscriberClients, err := createSubscriberClients(ctx, newProjectIDs)
+	if err != nil {
+		return errors.Wrap(err, "reload: could not create Pub/Sub subscriber clients")
+	}
+	for projectID, subscriberClient := range newSubscriberClients {
+		subscriberClients[projectID] = subscriberClient
+	}
+
+	err = applyTopics(ctx, cfg, clients, schemaClients, topics)
+	if err != nil {
+		return errors.Wrap(err, "reload")
+	}
+
+	srv.reload(allProjectIDs, clients, schemaClients, subscriberClients, topics)
+
+	logWithPrefix(
+		"reload: finished, supporting the following Google Cloud Platform projects: %s",
+		strings.Join(allProjectIDs, ", "),
+	)
+
+	return nil
+}
+
+// watchConfig reloads the config file whenever the process receives SIGHUP or the modification time of the file
+// changes. A failed reload is logged and leaves the running configuration untouched.
+func watchConfig(ctx context.Context, cfg *config, srv *Server) error {
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+	modTime := func() time.Time {
+		info, err := os.Stat(cfg.configFilePath)
+		if err != nil {
+			return time.Time{}
+		}
+
+		return info.ModTime()
+	}
+
+	lastModTime := modTime()
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	logWithPrefix("reload: watching config file %q", cfg.configFilePath)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hupCh:
+			logWithPrefix("reload: received SIGHUP")
+		case <-ticker.C:
+			mt := modTime()
+			if mt.IsZero() || mt.Equal(lastModTime) {
+				continue
+			}
+
+			logWithPrefix("reload: config file %q changed", cfg.configFilePath)
+		}
+
+		lastModTime = modTime()
+
+		err := reloadConfig(ctx, cfg, srv)
+		if err != nil {
+			logWithPrefix("reload: %+v", err)
+		}
+	}
+}
+
 func RunAppWithContext(ctx context.Context, additionalRouterConfigs ...func(chi.Router)) error {
 	logWithPrefix("application: starting")
 
@@ -107,19 +306,43 @@ func RunAppWithContext(ctx context.Context, additionalRouterConfigs ...func(chi.
 
 	projectsCh := make(chan []string)
 	clientsCh := make(chan map[string]*pubsub.Client)
+	schemaClientsCh := make(chan map[string]*pubsub.SchemaClient)
+	subscriberClientsCh := make(chan map[string]*vkit.SubscriberClient)
 	topicsCh := make(chan Topics)
 	topicsCreatedCh := make(chan struct{})
 
-	srvr := newServer(ctx, projectsCh, clientsCh, topicsCh, topicsCreatedCh, additionalRouterConfigs...)
+	srvr := newServer(
+		ctx,
+		projectsCh,
+		clientsCh,
+		schemaClientsCh,
+		subscriberClientsCh,
+		topicsCh,
+		topicsCreatedCh,
+		cfg.instance,
+		cfg.topicsCacheTTL,
+		additionalRouterConfigs...,
+	)
 
 	setupGroup := errgroup.Group{}
 	setupGroup.Go(func() error {
 		defer close(projectsCh)
 		defer close(clientsCh)
+		defer close(schemaClientsCh)
+		defer close(subscriberClientsCh)
 		defer close(topicsCh)
 		defer close(topicsCreatedCh)
 
-		err := doAppSetup(ctx, cfg.projectIDs, cfg.configFilePath, projectsCh, clientsCh, topicsCh, topicsCreatedCh)
+		err := doAppSetup(
+			ctx,
+			cfg,
+			projectsCh,
+			clientsCh,
+			schemaClientsCh,
+			subscriberClientsCh,
+			topicsCh,
+			topicsCreatedCh,
+		)
 		if err != nil {
 			return errors.Wrap(err, "setup: failed")
 		}
@@ -142,6 +365,19 @@ func RunAppWithContext(ctx context.Context, additionalRouterConfigs ...func(chi.
 		return errors.Wrap(err, "application: failed to start")
 	}
 
+	if cfg.configFilePath != "" {
+		runGroup.Go(func() error {
+			return watchConfig(ctx, cfg, srvr)
+		})
+	}
+
+	// Plan mode must not change anything, which includes deleting orphaned subscriptions.
+	if !cfg.plan {
+		runGroup.Go(func() error {
+			return runJanitor(ctx, srvr, cfg.cleanupStale)
+		})
+	}
+
 	err = runGroup.Wait()
 	if err != nil {
 		return errors.Wrap(err, "application: stopped with error")
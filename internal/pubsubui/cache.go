@@ -0,0 +1,164 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"sync"
+	"time"
+)
+
+type topicsCacheEntry struct {
+	topics   []Topic
+	loadedAt time.Time
+}
+
+// topicsCache holds the topics of each project, since listing all topics of a large project takes a while. Entries
+// expire after the TTL, so topics created or deleted by other tools eventually show up. A TTL of zero means entries
+// never expire.
+//
+// The cached slices are never modified, changes replace them instead, so they can be handed out without copying.
+// Every change to a project increases its generation, which tells whether topics of the project loaded in the meantime
+// are outdated. Changes to one project do not affect loading the topics of another.
+type topicsCache struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	entries     map[string]topicsCacheEntry
+	generations map[string]uint64
+}
+
+func newTopicsCache(ttl time.Duration) *topicsCache {
+	return &topicsCache{
+		ttl:         ttl,
+		entries:     make(map[string]topicsCacheEntry),
+		generations: make(map[string]uint64),
+	}
+}
+
+// get returns the cached topics of the project, unless they are not cached or have expired. It also returns the
+// current generation of the project, which is to be passed to set after loading the topics. The project is tracked
+// from then on, so invalidating all projects also outdates topics which are still being loaded.
+func (tc *topicsCache) get(projectID string) ([]Topic, uint64, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	generation, ok := tc.generations[projectID]
+	if !ok {
+		tc.generations[projectID] = generation
+	}
+
+	entry, ok := tc.entries[projectID]
+	if !ok {
+		return nil, generation, false
+	}
+
+	if tc.ttl > 0 && time.Since(entry.loadedAt) > tc.ttl {
+		delete(tc.entries, projectID)
+		return nil, generation, false
+	}
+
+	return entry.topics, generation, true
+}
+
+// set caches the topics of the project, which were loaded at the given generation. Topics loaded while the project
+// was changed or invalidated may already be outdated, so they are not cached.
+func (tc *topicsCache) set(projectID string, generation uint64, topics []Topic) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if tc.generations[projectID] != generation {
+		return
+	}
+
+	tc.entries[projectID] = topicsCacheEntry{
+		topics:   topics,
+		loadedAt: time.Now(),
+	}
+}
+
+// modify replaces the cached topics of the project with the result of fn. Projects which are not cached are left
+// alone, they are loaded in full when listed next.
+func (tc *topicsCache) modify(projectID string, fn func([]Topic) []Topic) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.generations[projectID]++
+
+	entry, ok := tc.entries[projectID]
+	if !ok {
+		return
+	}
+
+	entry.topics = fn(entry.topics)
+	tc.entries[projectID] = entry
+}
+
+// put adds the topic to the cached topics of its project, or replaces it when it is cached already.
+func (tc *topicsCache) put(topic Topic) {
+	tc.modify(topic.ProjectID, func(topics []Topic) []Topic {
+		modified := make([]Topic, 0, len(topics)+1)
+		replaced := false
+
+		for _, t := range topics {
+			if t.ID == topic.ID {
+				t = topic
+				replaced = true
+			}
+
+			modified = append(modified, t)
+		}
+
+		if !replaced {
+			modified = append(modified, topic)
+		}
+
+		return modified
+	})
+}
+
+// remove removes the topic from the cached topics of the project.
+func (tc *topicsCache) remove(projectID, topicID string) {
+	tc.modify(projectID, func(topics []Topic) []Topic {
+		modified := make([]Topic, 0, len(topics))
+
+		for _, t := range topics {
+			if t.ID != topicID {
+				modified = append(modified, t)
+			}
+		}
+
+		return modified
+	})
+}
+
+// invalidate drops the cached topics of the given projects, or of all projects when none are given.
+func (tc *topicsCache) invalidate(projectIDs ...string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if len(projectIDs) == 0 {
+		for projectID := range tc.generations {
+			tc.generations[projectID]++
+		}
+
+		tc.entries = make(map[string]topicsCacheEntry)
+		return
+	}
+
+	for _, projectID := range projectIDs {
+		tc.generations[projectID]++
+		delete(tc.entries, projectID)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopicsCacheGenerations(t *testing.T) {
+	topics := []Topic{{ID: "projects/a/topics/t", Name: "t", ProjectID: "a"}}
+
+	tests := []struct {
+		name string
+		// change happens between getting the generation and setting the loaded topics.
+		change     func(tc *topicsCache)
+		wantHit    bool
+		wantTopics []Topic
+	}{
+		{
+			name:       "unchanged",
+			change:     func(tc *topicsCache) {},
+			wantHit:    true,
+			wantTopics: topics,
+		},
+		{
+			name: "topic put",
+			change: func(tc *topicsCache) {
+				tc.put(Topic{ID: "projects/a/topics/u", Name: "u", ProjectID: "a"})
+			},
+		},
+		{
+			name: "topic removed",
+			change: func(tc *topicsCache) {
+				tc.remove("a", "projects/a/topics/t")
+			},
+		},
+		{
+			name: "project invalidated",
+			change: func(tc *topicsCache) {
+				tc.invalidate("a")
+			},
+		},
+		{
+			name: "all projects invalidated",
+			change: func(tc *topicsCache) {
+				tc.invalidate()
+			},
+		},
+		{
+			name: "other project invalidated",
+			change: func(tc *topicsCache) {
+				tc.invalidate("b")
+			},
+			wantHit:    true,
+			wantTopics: topics,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTopicsCache(0)
+
+			_, generation, ok := tc.get("a")
+			if ok {
+				t.Fatalf("expected empty cache to miss")
+			}
+
+			tt.change(tc)
+			tc.set("a", generation, topics)
+
+			got, _, ok := tc.get("a")
+			if ok != tt.wantHit {
+				t.Fatalf("expected hit to be %t, got %t", tt.wantHit, ok)
+			}
+			if !reflect.DeepEqual(got, tt.wantTopics) {
+				t.Errorf("expected topics %v, got %v", tt.wantTopics, got)
+			}
+		})
+	}
+}
+
+func TestTopicsCacheModify(t *testing.T) {
+	tc := newTopicsCache(0)
+
+	_, generation, _ := tc.get("a")
+	tc.set("a", generation, []Topic{{ID: "projects/a/topics/t", Name: "t", ProjectID: "a"}})
+
+	tc.put(Topic{ID: "projects/a/topics/t", Name: "t", ProjectID: "a", Labels: map[string]string{"team": "x"}})
+	tc.put(Topic{ID: "projects/a/topics/u", Name: "u", ProjectID: "a"})
+	tc.remove("a", "projects/a/topics/missing")
+
+	got, _, ok := tc.get("a")
+	if !ok {
+		t.Fatalf("expected modified topics to stay cached")
+	}
+
+	want := []Topic{
+		{ID: "projects/a/topics/t", Name: "t", ProjectID: "a", Labels: map[string]string{"team": "x"}},
+		{ID: "projects/a/topics/u", Name: "u", ProjectID: "a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected topics %v, got %v", want, got)
+	}
+}
+
+func TestTopicsCacheExpiration(t *testing.T) {
+	tc := newTopicsCache(time.Millisecond)
+
+	_, generation, _ := tc.get("a")
+	tc.set("a", generation, []Topic{{ID: "projects/a/topics/t", Name: "t", ProjectID: "a"}})
+
+	time.Sleep(time.Millisecond * 5)
+
+	_, _, ok := tc.get("a")
+	if ok {
+		t.Errorf("expected expired topics to miss")
+	}
+}
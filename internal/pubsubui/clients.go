@@ -16,9 +16,14 @@ package pubsubui
 
 import (
 	"context"
+	"os"
 
 	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
 	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func createClients(ctx context.Context, projectIDs []string) (map[string]*pubsub.Client, error) {
@@ -39,3 +44,74 @@ func createClients(ctx context.Context, projectIDs []string) (map[string]*pubsub
 
 	return clients, nil
 }
+
+// newSchemaClient creates a schema client which, just like the Pub/Sub client, connects to the emulator when
+// PUBSUB_EMULATOR_HOST is set. Unlike the Pub/Sub client the schema client does not do this by itself.
+func newSchemaClient(ctx context.Context, projectID string) (*pubsub.SchemaClient, error) {
+	addr := os.Getenv("PUBSUB_EMULATOR_HOST")
+	if addr == "" {
+		return pubsub.NewSchemaClient(ctx, projectID)
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not connect to emulator at %q", addr)
+	}
+
+	return pubsub.NewSchemaClient(ctx, projectID, option.WithGRPCConn(conn), option.WithTelemetryDisabled())
+}
+
+// newSubscriberClient creates a low level subscriber client, which unlike the Pub/Sub client leaves acking, nacking and
+// extending the ack deadline of messages entirely to the caller. Like the schema client it has to be pointed to the
+// emulator explicitly.
+func newSubscriberClient(ctx context.Context) (*vkit.SubscriberClient, error) {
+	addr := os.Getenv("PUBSUB_EMULATOR_HOST")
+	if addr == "" {
+		return vkit.NewSubscriberClient(ctx)
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not connect to emulator at %q", addr)
+	}
+
+	return vkit.NewSubscriberClient(ctx, option.WithGRPCConn(conn), option.WithTelemetryDisabled())
+}
+
+func createSchemaClients(ctx context.Context, projectIDs []string) (map[string]*pubsub.SchemaClient, error) {
+	schemaClients := make(map[string]*pubsub.SchemaClient)
+
+	for _, projectID := range projectIDs {
+		logWithPrefix("clients: creating schema client: for project %q", projectID)
+
+		schemaClient, err := newSchemaClient(ctx, projectID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "clients: could not create schema client for project %q", projectID)
+		}
+
+		schemaClients[projectID] = schemaClient
+
+		logWithPrefix("clients: created schema client for project %q", projectID)
+	}
+
+	return schemaClients, nil
+}
+
+func createSubscriberClients(ctx context.Context, projectIDs []string) (map[string]*vkit.SubscriberClient, error) {
+	subscriberClients := make(map[string]*vkit.SubscriberClient)
+
+	for _, projectID := range projectIDs {
+		logWithPrefix("clients: creating subscriber client: for project %q", projectID)
+
+		subscriberClient, err := newSubscriberClient(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "clients: could not create subscriber client for project %q", projectID)
+		}
+
+		subscriberClients[projectID] = subscriberClient
+
+		logWithPrefix("clients: created subscriber client for project %q", projectID)
+	}
+
+	return subscriberClients, nil
+}
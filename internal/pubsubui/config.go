@@ -16,32 +16,53 @@ package pubsubui
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/lithammer/shortuuid/v4"
 	"github.com/pkg/errors"
 )
 
 const (
-	envKeyHost     = "PUBSUBUI_HOST"
-	envKeyPort     = "PUBSUBUI_PORT"
-	envKeyConfig   = "PUBSUBUI_CONFIG"
-	envKeyProjects = "GOOGLE_CLOUD_PROJECTS"
+	envKeyHost      = "PUBSUBUI_HOST"
+	envKeyPort      = "PUBSUBUI_PORT"
+	envKeyConfig    = "PUBSUBUI_CONFIG"
+	envKeyProjects  = "GOOGLE_CLOUD_PROJECTS"
+	envKeyReconcile = "PUBSUBUI_RECONCILE"
+	envKeyPrune     = "PUBSUBUI_PRUNE"
+	envKeyPlan      = "PUBSUBUI_PLAN"
+	envKeyInstance  = "PUBSUBUI_INSTANCE"
+	envKeyCacheTTL  = "PUBSUBUI_TOPICS_CACHE_TTL"
+	envKeyCleanup   = "PUBSUBUI_CLEANUP_STALE"
 )
 
 const (
-	flagNameHost     = "host"
-	flagNamePort     = "port"
-	flagNameConfig   = "config"
-	flagNameProjects = "projects"
+	flagNameHost      = "host"
+	flagNamePort      = "port"
+	flagNameConfig    = "config"
+	flagNameProjects  = "projects"
+	flagNameReconcile = "reconcile"
+	flagNamePrune     = "prune"
+	flagNamePlan      = "plan"
+	flagNameInstance  = "instance"
+	flagNameCacheTTL  = "topics-cache-ttl"
+	flagNameCleanup   = "cleanup-stale"
 )
 
 var (
-	defaultValueHost     = "0.0.0.0"
-	defaultValuePort     = uint(8080)
-	defaultValueConfig   = ""
-	defaultValueProjects = ""
+	defaultValueHost      = "0.0.0.0"
+	defaultValuePort      = uint(8080)
+	defaultValueConfig    = ""
+	defaultValueProjects  = ""
+	defaultValueReconcile = false
+	defaultValuePrune     = false
+	defaultValuePlan      = false
+	defaultValueInstance  = ""
+	defaultValueCacheTTL  = time.Minute * 5
+	defaultValueCleanup   = false
 )
 
 var (
@@ -53,6 +74,36 @@ var (
 		defaultValueProjects,
 		"The Google Cloud Platform projects to target (if not set in the config file)",
 	)
+	flagReconcile = flag.Bool(
+		flagNameReconcile,
+		defaultValueReconcile,
+		"Update existing topics and subscriptions to match the config file",
+	)
+	flagPrune = flag.Bool(
+		flagNamePrune,
+		defaultValuePrune,
+		"Delete topics and subscriptions managed by pubsubui which are no longer in the config file (requires -reconcile)",
+	)
+	flagPlan = flag.Bool(
+		flagNamePlan,
+		defaultValuePlan,
+		"Only print which topics and subscriptions would be created, updated or deleted, without changing them",
+	)
+	flagInstance = flag.String(
+		flagNameInstance,
+		defaultValueInstance,
+		"The name of this instance, used to clean up the subscriptions it left behind (unique per process by default)",
+	)
+	flagCacheTTL = flag.Duration(
+		flagNameCacheTTL,
+		defaultValueCacheTTL,
+		"How long the topics of a project are cached before they are listed again (0 caches them forever)",
+	)
+	flagCleanup = flag.Bool(
+		flagNameCleanup,
+		defaultValueCleanup,
+		"Also delete the streaming subscriptions of other instances whose heartbeat is stale",
+	)
 )
 
 type config struct {
@@ -60,12 +111,43 @@ type config struct {
 	port           uint
 	configFilePath string
 	projectIDs     []string
+	reconcile      bool
+	prune          bool
+	plan           bool
+	instance       string
+	topicsCacheTTL time.Duration
+	cleanupStale   bool
+}
+
+// defaultInstance names the instance after the hostname with a random suffix, so every process gets its own name, even
+// when processes share a hostname or a restarted process gets the same one. The name fits in a label value.
+func defaultInstance() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", errors.Wrap(err, "could not determine hostname")
+	}
+
+	suffix := strings.ToLower(shortuuid.New()[:8])
+	if len(hostname) > 63-len(suffix)-1 {
+		hostname = hostname[:63-len(suffix)-1]
+	}
+
+	return fmt.Sprintf("%s-%s", hostname, suffix), nil
 }
 
 func parseString(v string) (string, error) {
 	return v, nil
 }
 
+func parseBool(v string) (bool, error) {
+	pv, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid bool: %s", v)
+	}
+
+	return pv, nil
+}
+
 func parseUint(v string) (uint, error) {
 	pv, err := strconv.ParseUint(v, 10, 64)
 	if err != nil {
@@ -75,6 +157,15 @@ func parseUint(v string) (uint, error) {
 	return uint(pv), nil
 }
 
+func parseDuration(v string) (time.Duration, error) {
+	pv, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid duration: %s", v)
+	}
+
+	return pv, nil
+}
+
 func foo[T any](envKey, flagName string, flagVal *T, defVal *T, parseFn func(string) (T, error)) (T, error) {
 	// Try to get the value from the environment first.
 	envVal, ok := os.LookupEnv(envKey)
@@ -124,11 +215,59 @@ func newConfig() (*config, error) {
 	}
 	projectIDs := filterEmptyStrings(strings.Split(projectIDsStr, ","))
 
+	reconcile, err := foo(envKeyReconcile, flagNameReconcile, flagReconcile, &defaultValueReconcile, parseBool)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: could not configure reconcile mode")
+	}
+
+	prune, err := foo(envKeyPrune, flagNamePrune, flagPrune, &defaultValuePrune, parseBool)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: could not configure prune mode")
+	}
+	if prune && !reconcile {
+		return nil, errors.New("config: prune mode requires reconcile mode")
+	}
+
+	plan, err := foo(envKeyPlan, flagNamePlan, flagPlan, &defaultValuePlan, parseBool)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: could not configure plan mode")
+	}
+
+	instance, err := foo(envKeyInstance, flagNameInstance, flagInstance, &defaultValueInstance, parseString)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: could not configure instance")
+	}
+	if instance == "" {
+		instance, err = defaultInstance()
+		if err != nil {
+			return nil, errors.Wrap(err, "config: could not determine instance")
+		}
+	}
+
+	topicsCacheTTL, err := foo(envKeyCacheTTL, flagNameCacheTTL, flagCacheTTL, &defaultValueCacheTTL, parseDuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: could not configure topics cache TTL")
+	}
+	if topicsCacheTTL < 0 {
+		return nil, errors.Errorf("config: topics cache TTL cannot be negative, got %s", topicsCacheTTL)
+	}
+
+	cleanupStale, err := foo(envKeyCleanup, flagNameCleanup, flagCleanup, &defaultValueCleanup, parseBool)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: could not configure cleanup of stale subscriptions")
+	}
+
 	cfg := config{
 		host:           host,
 		port:           uint(port),
 		configFilePath: configFilePath,
 		projectIDs:     projectIDs,
+		reconcile:      reconcile,
+		prune:          prune,
+		plan:           plan,
+		instance:       instance,
+		topicsCacheTTL: topicsCacheTTL,
+		cleanupStale:   cleanupStale,
 	}
 
 	logWithPrefix("application: config: created")
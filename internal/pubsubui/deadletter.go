@@ -0,0 +1,189 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pkg/errors"
+)
+
+var timeoutRedriveIdle = time.Second * 5
+
+// Pub/Sub adds these attributes to the messages it forwards to a dead letter topic, next to the original attributes.
+const (
+	attributePrefixDeadLetter                    = "CloudPubSubDeadLetter"
+	attributeDeadLetterSourceDeliveryCount       = "CloudPubSubDeadLetterSourceDeliveryCount"
+	attributeDeadLetterSourceSubscription        = "CloudPubSubDeadLetterSourceSubscription"
+	attributeDeadLetterSourceSubscriptionProject = "CloudPubSubDeadLetterSourceSubscriptionProject"
+	attributeDeadLetterSourceTopicPublishTime    = "CloudPubSubDeadLetterSourceTopicPublishTime"
+)
+
+// DeadLetterSource describes where a dead lettered message came from.
+type DeadLetterSource struct {
+	Subscription     string `json:"subscription,omitempty"`
+	ProjectID        string `json:"projectId,omitempty"`
+	DeliveryCount    int    `json:"deliveryCount,omitempty"`
+	TopicPublishTime string `json:"topicPublishTime,omitempty"`
+}
+
+// splitDeadLetterAttributes separates the attributes Pub/Sub adds when dead lettering a message from the original
+// attributes of the message. The source is nil when the message was not dead lettered.
+func splitDeadLetterAttributes(attrs map[string]string) (map[string]string, *DeadLetterSource) {
+	var source *DeadLetterSource
+	original := make(map[string]string, len(attrs))
+
+	for key, val := range attrs {
+		if !strings.HasPrefix(key, attributePrefixDeadLetter) {
+			original[key] = val
+			continue
+		}
+
+		if source == nil {
+			source = &DeadLetterSource{}
+		}
+
+		switch key {
+		case attributeDeadLetterSourceDeliveryCount:
+			source.DeliveryCount, _ = strconv.Atoi(val)
+		case attributeDeadLetterSourceSubscription:
+			source.Subscription = val
+		case attributeDeadLetterSourceSubscriptionProject:
+			source.ProjectID = val
+		case attributeDeadLetterSourceTopicPublishTime:
+			source.TopicPublishTime = val
+		}
+	}
+
+	if len(original) == 0 {
+		original = nil
+	}
+
+	return original, source
+}
+
+// deadLetterTopicPath splits the fully qualified name of a dead letter topic into its project and topic ID.
+func deadLetterTopicPath(name string) (string, string, error) {
+	split := strings.Split(name, "/")
+	if len(split) != 4 || split[0] != "projects" || split[2] != "topics" {
+		return "", "", errors.Errorf("invalid dead letter topic %q", name)
+	}
+
+	return split[1], split[3], nil
+}
+
+// redriveRequest selects the dead lettered messages to republish by ID. Without any IDs all messages are republished.
+// The subscription on the dead letter topic can be left empty when the topic has exactly one subscription.
+type redriveRequest struct {
+	Subscription string   `json:"subscription"`
+	MessageIDs   []string `json:"messageIds"`
+	IdleTimeout  Duration `json:"idleTimeout"`
+}
+
+type redriveResult struct {
+	MessageID    string `json:"messageId"`
+	NewMessageID string `json:"newMessageId,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// errRedriveNotReceived is the error of a selected message which was not received from the dead letter subscription
+// before the idle timeout, because it was redriven before, was never dead lettered or is leased by another consumer.
+const errRedriveNotReceived = "message not received from the dead letter subscription, it was not redriven"
+
+// redrive republishes dead lettered messages to the source topic. A message is only acked on the dead letter
+// subscription after it has been published successfully, otherwise it is nacked so it stays dead lettered. Receiving
+// stops once all selected messages have been handled, or when no selected message arrived within the idle timeout.
+// Every selected message gets a result, also when it was not received.
+func redrive(
+	ctx context.Context,
+	dlqSub *pubsub.Subscription,
+	sourceTopic *pubsub.Topic,
+	messageIDs []string,
+	idleTimeout time.Duration,
+) ([]redriveResult, error) {
+	// Publishing uses the outer context, so a publish in progress is not cancelled when receiving stops.
+	receiveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	selected := make(map[string]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		selected[id] = true
+	}
+
+	var mu sync.Mutex
+	handled := make(map[string]bool)
+	results := make([]redriveResult, 0)
+
+	idle := time.AfterFunc(idleTimeout, cancel)
+	defer idle.Stop()
+
+	err := dlqSub.Receive(receiveCtx, func(_ context.Context, msg *pubsub.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if handled[msg.ID] || (len(selected) > 0 && !selected[msg.ID]) {
+			msg.Nack()
+			return
+		}
+
+		idle.Reset(idleTimeout)
+		handled[msg.ID] = true
+
+		attrs, _ := splitDeadLetterAttributes(msg.Attributes)
+
+		result := redriveResult{MessageID: msg.ID}
+
+		id, err := publishMessage(ctx, sourceTopic, &pubsub.Message{
+			Data:        msg.Data,
+			Attributes:  attrs,
+			OrderingKey: msg.OrderingKey,
+		})
+		if err != nil {
+			msg.Nack()
+			result.Error = err.Error()
+		} else {
+			msg.Ack()
+			result.NewMessageID = id
+		}
+
+		results = append(results, result)
+
+		if len(selected) > 0 && len(handled) == len(selected) {
+			cancel()
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not receive dead lettered messages")
+	}
+
+	for _, id := range messageIDs {
+		if handled[id] {
+			continue
+		}
+
+		handled[id] = true
+		results = append(results, redriveResult{
+			MessageID: id,
+			Error:     errRedriveNotReceived,
+		})
+	}
+
+	return results, nil
+}
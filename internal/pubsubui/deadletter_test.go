@@ -0,0 +1,136 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestRedrive(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	dlqTopic, err := client.CreateTopic(ctx, "dead-letters")
+	if err != nil {
+		t.Fatalf("could not create dead letter topic: %v", err)
+	}
+	defer dlqTopic.Stop()
+
+	dlqSub, err := client.CreateSubscription(ctx, "dead-letters-sub", pubsub.SubscriptionConfig{Topic: dlqTopic})
+	if err != nil {
+		t.Fatalf("could not create dead letter subscription: %v", err)
+	}
+
+	_, err = client.CreateTopic(ctx, "source")
+	if err != nil {
+		t.Fatalf("could not create source topic: %v", err)
+	}
+
+	dlqID, err := dlqTopic.Publish(ctx, &pubsub.Message{
+		Data: []byte("hello"),
+		Attributes: map[string]string{
+			"type":                                 "greeting",
+			attributeDeadLetterSourceDeliveryCount: "5",
+		},
+	}).Get(ctx)
+	if err != nil {
+		t.Fatalf("could not publish dead letter: %v", err)
+	}
+
+	sourceTopic := client.Topic("source")
+	sourceTopic.EnableMessageOrdering = true
+	defer sourceTopic.Stop()
+
+	results, err := redrive(ctx, dlqSub, sourceTopic, []string{dlqID, "unknown"}, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+
+	if results[0].MessageID != dlqID || results[0].NewMessageID == "" || results[0].Error != "" {
+		t.Errorf("expected %q to be redriven, got %+v", dlqID, results[0])
+	}
+
+	if results[1].MessageID != "unknown" || results[1].NewMessageID != "" || results[1].Error != errRedriveNotReceived {
+		t.Errorf("expected %q to be reported as not redriven, got %+v", "unknown", results[1])
+	}
+}
+
+func TestSplitDeadLetterAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		attrs      map[string]string
+		want       map[string]string
+		wantSource *DeadLetterSource
+	}{
+		{name: "no attributes"},
+		{
+			name:  "not dead lettered",
+			attrs: map[string]string{"origin": "test"},
+			want:  map[string]string{"origin": "test"},
+		},
+		{
+			name: "dead lettered",
+			attrs: map[string]string{
+				"origin":                                     "test",
+				attributeDeadLetterSourceDeliveryCount:       "5",
+				attributeDeadLetterSourceSubscription:        "orders-sub",
+				attributeDeadLetterSourceSubscriptionProject: "my-project",
+				attributeDeadLetterSourceTopicPublishTime:    "2022-01-01T00:00:00Z",
+			},
+			want: map[string]string{"origin": "test"},
+			wantSource: &DeadLetterSource{
+				Subscription:     "orders-sub",
+				ProjectID:        "my-project",
+				DeliveryCount:    5,
+				TopicPublishTime: "2022-01-01T00:00:00Z",
+			},
+		},
+		{
+			name: "only dead letter attributes",
+			attrs: map[string]string{
+				attributeDeadLetterSourceSubscription: "orders-sub",
+				attributePrefixDeadLetter + "Unknown": "ignored",
+			},
+			wantSource: &DeadLetterSource{Subscription: "orders-sub"},
+		},
+		{
+			name:       "invalid delivery count",
+			attrs:      map[string]string{attributeDeadLetterSourceDeliveryCount: "many"},
+			wantSource: &DeadLetterSource{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, source := splitDeadLetterAttributes(tt.attrs)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected attributes %v, got %v", tt.want, got)
+			}
+			if !reflect.DeepEqual(source, tt.wantSource) {
+				t.Errorf("expected source %+v, got %+v", tt.wantSource, source)
+			}
+		})
+	}
+}
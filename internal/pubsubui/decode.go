@@ -0,0 +1,281 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/linkedin/goavro/v2"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// messageDecoder turns the data of a message into JSON.
+type messageDecoder interface {
+	decode(data []byte) (json.RawMessage, error)
+}
+
+// jsonDecoder is used for messages which are JSON encoded according to their schema, so they only need checking.
+type jsonDecoder struct{}
+
+func (jsonDecoder) decode(data []byte) (json.RawMessage, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("data is not valid JSON")
+	}
+
+	return data, nil
+}
+
+type avroDecoder struct {
+	codec *goavro.Codec
+}
+
+func (d avroDecoder) decode(data []byte) (json.RawMessage, error) {
+	native, _, err := d.codec.NativeFromBinary(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode Avro data")
+	}
+
+	bts, err := d.codec.TextualFromNative(nil, native)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode Avro data as JSON")
+	}
+
+	return bts, nil
+}
+
+type protobufDecoder struct {
+	descriptor protoreflect.MessageDescriptor
+}
+
+func (d protobufDecoder) decode(data []byte) (json.RawMessage, error) {
+	msg := dynamicpb.NewMessage(d.descriptor)
+
+	err := proto.Unmarshal(data, msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decode protobuf data as %q", d.descriptor.FullName())
+	}
+
+	bts, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not encode protobuf data of %q as JSON", d.descriptor.FullName())
+	}
+
+	return bts, nil
+}
+
+// messageDescriptor finds a message type in a set of file descriptors. Without a message type the first message of
+// the first file is used.
+func messageDescriptor(
+	fdset *descriptorpb.FileDescriptorSet,
+	messageType string,
+) (protoreflect.MessageDescriptor, error) {
+	files, err := protodesc.NewFiles(fdset)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid file descriptor set")
+	}
+
+	if messageType == "" {
+		if len(fdset.File) == 0 || len(fdset.File[0].MessageType) == 0 {
+			return nil, errors.New("no message types defined")
+		}
+
+		fd := fdset.File[0]
+		messageType = fd.GetMessageType()[0].GetName()
+		if pkg := fd.GetPackage(); pkg != "" {
+			messageType = pkg + "." + messageType
+		}
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find message type %q", messageType)
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, errors.Errorf("%q is not a message type", messageType)
+	}
+
+	return md, nil
+}
+
+// protobufSchemaDescriptor parses the definition of a protobuf schema. Pub/Sub requires the definition to hold
+// exactly one top-level message type, which is the type of the messages.
+func protobufSchemaDescriptor(definition string) (protoreflect.MessageDescriptor, error) {
+	const fileName = "schema.proto"
+
+	parser := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{fileName: definition}),
+	}
+
+	fds, err := parser.ParseFiles(fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse protobuf schema")
+	}
+
+	return messageDescriptor(desc.ToFileDescriptorSet(fds...), "")
+}
+
+func newSchemaDecoder(cfg pubsub.SchemaConfig, encoding pubsub.SchemaEncoding) (messageDecoder, error) {
+	if encoding == pubsub.EncodingJSON {
+		return jsonDecoder{}, nil
+	}
+
+	switch cfg.Type {
+	case pubsub.SchemaAvro:
+		codec, err := goavro.NewCodec(cfg.Definition)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse Avro schema")
+		}
+
+		return avroDecoder{codec: codec}, nil
+	case pubsub.SchemaProtocolBuffer:
+		md, err := protobufSchemaDescriptor(cfg.Definition)
+		if err != nil {
+			return nil, err
+		}
+
+		return protobufDecoder{descriptor: md}, nil
+	default:
+		return nil, errors.Errorf("unsupported schema type %d", cfg.Type)
+	}
+}
+
+// schemaDecoder creates a decoder for the named schema. The name is either the ID of a schema in the given project or
+// a fully qualified schema name, which can refer to any configured project.
+func (srv *Server) schemaDecoder(
+	ctx context.Context,
+	projectID string,
+	schemaName string,
+	encoding pubsub.SchemaEncoding,
+) (messageDecoder, error) {
+	schemaID := schemaName
+	if split := strings.Split(schemaName, "/"); len(split) == 4 && split[0] == "projects" && split[2] == "schemas" {
+		projectID = split[1]
+		schemaID = split[3]
+	}
+
+	schemaClient, ok := srv.schemaClient(projectID)
+	if !ok {
+		return nil, errors.Errorf("no schema client configured for project %q", projectID)
+	}
+
+	cfg, err := schemaClient.Schema(ctx, schemaID, pubsub.SchemaViewFull)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get schema %q in project %q", schemaID, projectID)
+	}
+
+	decoder, err := newSchemaDecoder(*cfg, encoding)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create decoder for schema %q in project %q", schemaID, projectID)
+	}
+
+	return decoder, nil
+}
+
+// topicDecoder creates a decoder for the schema the topic is bound to, if any.
+func (srv *Server) topicDecoder(ctx context.Context, projectID string, topic *pubsub.Topic) (messageDecoder, error) {
+	cfg, err := topic.Config(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get config of topic %q", topic.String())
+	}
+
+	if cfg.SchemaSettings == nil {
+		return nil, nil
+	}
+
+	return srv.schemaDecoder(ctx, projectID, cfg.SchemaSettings.Schema, cfg.SchemaSettings.Encoding)
+}
+
+// streamDecoder determines how to decode the messages of a stream. A schema supplied by the user takes precedence
+// over a protobuf message type registered for the topic, which in turn takes precedence over the schema of the topic.
+// Without any of these the messages are streamed as is when they hold JSON, or as base64.
+func (srv *Server) streamDecoder(
+	ctx context.Context,
+	qry url.Values,
+	projectID string,
+	topic *pubsub.Topic,
+) (messageDecoder, error) {
+	schemaName := getQueryParamOrDefault(qry, queryParamKeySchema, "")
+	if schemaName != "" {
+		encoding, err := schemaEncoding(getQueryParamOrDefault(qry, queryParamKeySchemaEncoding, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		return srv.schemaDecoder(ctx, projectID, schemaName, encoding)
+	}
+
+	if md, ok := srv.topicProtobufType(projectID, topicNameFromTopicID(topic.String())); ok {
+		return protobufDecoder{descriptor: md}, nil
+	}
+
+	decoder, err := srv.topicDecoder(ctx, projectID, topic)
+	if err != nil {
+		// Not being able to decode is no reason not to stream, since the messages can still be streamed as base64.
+		logWithPrefix("server: streaming without schema: %+v", err)
+		return nil, nil
+	}
+
+	return decoder, nil
+}
+
+// messageData turns the data of a message into something which can safely be embedded in JSON, together with the
+// encoding of the result. Data which is neither JSON nor decodable is returned as a string when it is valid UTF-8 and
+// as base64 otherwise, together with the decoding error if there was one.
+func messageData(data []byte, decoder messageDecoder) (json.RawMessage, string, error) {
+	var decodeErr error
+
+	if decoder != nil {
+		decoded, err := decoder.decode(data)
+		if err == nil {
+			return decoded, encodingJSON, nil
+		}
+
+		decodeErr = err
+	} else if json.Valid(data) {
+		return data, encodingJSON, nil
+	}
+
+	if utf8.Valid(data) {
+		encoded, err := json.Marshal(string(data))
+		if err != nil {
+			return nil, "", errors.Wrap(err, "could not encode data as JSON string")
+		}
+
+		return encoded, encodingText, decodeErr
+	}
+
+	encoded, err := json.Marshal(base64.StdEncoding.EncodeToString(data))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "could not encode data as base64 JSON string")
+	}
+
+	return encoded, encodingBase64, decodeErr
+}
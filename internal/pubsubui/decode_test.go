@@ -0,0 +1,86 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type failingDecoder struct{}
+
+func (failingDecoder) decode([]byte) (json.RawMessage, error) {
+	return nil, errors.New("cannot decode")
+}
+
+func TestMessageData(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		decoder      messageDecoder
+		want         string
+		wantEncoding string
+		wantErr      bool
+	}{
+		{name: "JSON", data: []byte(`{"a":1}`), want: `{"a":1}`, wantEncoding: encodingJSON},
+		{name: "text", data: []byte("hello"), want: `"hello"`, wantEncoding: encodingText},
+		{name: "binary", data: []byte{0xff, 0xfe}, want: `"//4="`, wantEncoding: encodingBase64},
+		{name: "empty", data: []byte{}, want: `""`, wantEncoding: encodingText},
+		{
+			name:         "decoded",
+			data:         []byte(`{"a":1}`),
+			decoder:      jsonDecoder{},
+			want:         `{"a":1}`,
+			wantEncoding: encodingJSON,
+		},
+		{
+			name:         "text which cannot be decoded",
+			data:         []byte("hello"),
+			decoder:      failingDecoder{},
+			want:         `"hello"`,
+			wantEncoding: encodingText,
+			wantErr:      true,
+		},
+		{
+			name:         "binary which cannot be decoded",
+			data:         []byte{0xff, 0xfe},
+			decoder:      failingDecoder{},
+			want:         `"//4="`,
+			wantEncoding: encodingBase64,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, encoding, err := messageData(tt.data, tt.decoder)
+			if tt.wantErr && err == nil {
+				t.Errorf("expected decoding error, got none")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("expected data %s, got %s", tt.want, got)
+			}
+			if encoding != tt.wantEncoding {
+				t.Errorf("expected encoding %q, got %q", tt.wantEncoding, encoding)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/lithammer/shortuuid/v4"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+var janitorInterval = time.Hour
+
+const (
+	// ephemeralSubscriptionInfix marks the subscriptions pubsubui creates to stream the messages of a topic.
+	ephemeralSubscriptionInfix = "_pubsubui_"
+	// ephemeralSubscriptionExpiration is the shortest expiration policy Pub/Sub allows. It makes sure ephemeral
+	// subscriptions are eventually deleted, even when pubsubui does not get the chance to clean them up.
+	ephemeralSubscriptionExpiration = time.Hour * 24
+	// ephemeralSubscriptionStaleAfter is how long the heartbeat of an ephemeral subscription may go without being
+	// refreshed before instances cleaning up stale subscriptions delete it. Heartbeats are refreshed on every janitor
+	// run, so this has to be well over the janitor interval.
+	ephemeralSubscriptionStaleAfter = time.Hour * 3
+)
+
+const (
+	labelKeyEphemeral   = "pubsubui-ephemeral"
+	labelValueEphemeral = "true"
+	labelKeyInstance    = "pubsubui-instance"
+	// labelKeyHeartbeat holds the Unix time at which the instance streaming from an ephemeral subscription last
+	// reported it as active.
+	labelKeyHeartbeat = "pubsubui-heartbeat"
+)
+
+// labelValue turns s into a valid label value, which may only hold lowercase letters, digits, underscores and dashes
+// and can be at most 63 characters long.
+func labelValue(s string) string {
+	var sb strings.Builder
+
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('-')
+		}
+	}
+
+	v := sb.String()
+	if len(v) > 63 {
+		v = v[:63]
+	}
+
+	return v
+}
+
+func ephemeralSubscriptionName(topicName string) string {
+	return fmt.Sprintf("%s%s%s", topicName, ephemeralSubscriptionInfix, shortuuid.New())
+}
+
+func heartbeatLabelValue(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func ephemeralSubscriptionConfig(topic *pubsub.Topic, instance string) pubsub.SubscriptionConfig {
+	return pubsub.SubscriptionConfig{
+		Topic:            topic,
+		ExpirationPolicy: ephemeralSubscriptionExpiration,
+		Labels: map[string]string{
+			labelKeyEphemeral: labelValueEphemeral,
+			labelKeyInstance:  labelValue(instance),
+			labelKeyHeartbeat: heartbeatLabelValue(time.Now()),
+		},
+	}
+}
+
+func isEphemeralSubscription(cfg pubsub.SubscriptionConfig) bool {
+	return strings.Contains(cfg.ID(), ephemeralSubscriptionInfix) &&
+		cfg.Labels[labelKeyEphemeral] == labelValueEphemeral
+}
+
+// isOrphanedSubscription tells whether a subscription is an ephemeral subscription which is no longer being streamed
+// from. Subscriptions of the given instance are orphaned as soon as they are no longer active. Whether subscriptions of
+// other instances, like the processes this one replaced, are still being streamed from can only be told from their
+// heartbeat, so these are only orphaned once it is stale and cleaning them up was opted into. Subscriptions without a
+// heartbeat are left to their expiration policy.
+func isOrphanedSubscription(
+	cfg pubsub.SubscriptionConfig,
+	instance string,
+	active func(string) bool,
+	cleanupStale bool,
+	now time.Time,
+) bool {
+	if !isEphemeralSubscription(cfg) {
+		return false
+	}
+
+	if cfg.Labels[labelKeyInstance] == labelValue(instance) {
+		return !active(cfg.ID())
+	}
+
+	if !cleanupStale {
+		return false
+	}
+
+	heartbeat, err := strconv.ParseInt(cfg.Labels[labelKeyHeartbeat], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(time.Unix(heartbeat, 0)) > ephemeralSubscriptionStaleAfter
+}
+
+// refreshHeartbeat tells the other instances the ephemeral subscription is still being streamed from.
+func refreshHeartbeat(ctx context.Context, client *pubsub.Client, cfg pubsub.SubscriptionConfig, now time.Time) error {
+	labels := make(map[string]string, len(cfg.Labels))
+	for k, v := range cfg.Labels {
+		labels[k] = v
+	}
+	labels[labelKeyHeartbeat] = heartbeatLabelValue(now)
+
+	_, err := client.Subscription(cfg.ID()).Update(ctx, pubsub.SubscriptionConfigToUpdate{
+		Labels: labels,
+	})
+
+	return err
+}
+
+// cleanupSubscriptions deletes the orphaned ephemeral subscriptions in the project of the client and refreshes the
+// heartbeat of the ones the given instance is still streaming from. A subscription which cannot be deleted or updated
+// is logged and left for the next run.
+func cleanupSubscriptions(
+	ctx context.Context,
+	client *pubsub.Client,
+	instance string,
+	active func(string) bool,
+	cleanupStale bool,
+) (int, error) {
+	deleted := 0
+	now := time.Now()
+
+	subIt := client.Subscriptions(ctx)
+	for {
+		cfg, err := subIt.NextConfig()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return deleted, errors.Wrap(err, "could not list subscriptions")
+		}
+
+		if isEphemeralSubscription(*cfg) && cfg.Labels[labelKeyInstance] == labelValue(instance) && active(cfg.ID()) {
+			err = refreshHeartbeat(ctx, client, *cfg, now)
+			if err != nil {
+				logWithPrefix("janitor: %+v", errors.Wrapf(err, "could not refresh heartbeat of subscription %q", cfg.ID()))
+			}
+
+			continue
+		}
+
+		if !isOrphanedSubscription(*cfg, instance, active, cleanupStale, now) {
+			continue
+		}
+
+		err = client.Subscription(cfg.ID()).Delete(ctx)
+		if err != nil {
+			logWithPrefix("janitor: %+v", errors.Wrapf(err, "could not delete subscription %q", cfg.ID()))
+			continue
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// runJanitor deletes the ephemeral subscriptions this instance no longer streams from and, when cleanupStale is set,
+// the stale ones of other instances, once at startup and then periodically. Failures are logged and retried on the
+// next run.
+func runJanitor(ctx context.Context, srv *Server, cleanupStale bool) error {
+	cleanup := func() {
+		for projectID, client := range srv.allClients() {
+			deleted, err := cleanupSubscriptions(ctx, client, srv.instance, srv.sse.active, cleanupStale)
+			if err != nil {
+				logWithPrefix("janitor: project %q: %+v", projectID, err)
+			}
+			if deleted > 0 {
+				logWithPrefix("janitor: deleted %d orphaned subscriptions in project %q", deleted, projectID)
+			}
+		}
+	}
+
+	logWithPrefix("janitor: cleaning up orphaned subscriptions of instance %q", srv.instance)
+
+	cleanup()
+
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			cleanup()
+		}
+	}
+}
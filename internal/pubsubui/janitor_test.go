@@ -0,0 +1,111 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/iterator"
+)
+
+func TestCleanupSubscriptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		cleanupStale bool
+		want         []string
+	}{
+		{
+			name:         "own subscriptions only",
+			cleanupStale: false,
+			want:         []string{"regular", "topic_pubsubui_active", "topic_pubsubui_fresh", "topic_pubsubui_stale"},
+		},
+		{
+			name:         "stale subscriptions of other instances",
+			cleanupStale: true,
+			want:         []string{"regular", "topic_pubsubui_active", "topic_pubsubui_fresh"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			client := newTestClient(t)
+
+			topic, err := client.CreateTopic(ctx, "topic")
+			if err != nil {
+				t.Fatalf("could not create topic: %v", err)
+			}
+
+			subscriptions := []struct {
+				id        string
+				instance  string
+				heartbeat time.Time
+			}{
+				{id: "topic_pubsubui_active", instance: "this", heartbeat: time.Now()},
+				{id: "topic_pubsubui_orphaned", instance: "this", heartbeat: time.Now()},
+				{id: "topic_pubsubui_fresh", instance: "other", heartbeat: time.Now()},
+				{id: "topic_pubsubui_stale", instance: "other", heartbeat: time.Now().Add(-ephemeralSubscriptionStaleAfter * 2)},
+			}
+			for _, sub := range subscriptions {
+				cfg := ephemeralSubscriptionConfig(topic, sub.instance)
+				cfg.Labels[labelKeyHeartbeat] = heartbeatLabelValue(sub.heartbeat)
+
+				_, err = client.CreateSubscription(ctx, sub.id, cfg)
+				if err != nil {
+					t.Fatalf("could not create subscription %q: %v", sub.id, err)
+				}
+			}
+
+			_, err = client.CreateSubscription(ctx, "regular", pubsub.SubscriptionConfig{Topic: topic})
+			if err != nil {
+				t.Fatalf("could not create subscription: %v", err)
+			}
+
+			active := func(id string) bool {
+				return id == "topic_pubsubui_active"
+			}
+
+			_, err = cleanupSubscriptions(ctx, client, "this", active, tt.cleanupStale)
+			if err != nil {
+				t.Fatalf("could not clean up subscriptions: %v", err)
+			}
+
+			var got []string
+			subIt := client.Subscriptions(ctx)
+			for {
+				sub, err := subIt.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					t.Fatalf("could not list subscriptions: %v", err)
+				}
+
+				got = append(got, sub.ID())
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected remaining subscriptions %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	labelOperatorEquals    = "="
+	labelOperatorNotEquals = "!="
+	labelOperatorExists    = "exists"
+	labelOperatorNotExists = "!exists"
+)
+
+type labelRequirement struct {
+	key      string
+	operator string
+	value    string
+}
+
+func (lr labelRequirement) matches(labels map[string]string) bool {
+	val, ok := labels[lr.key]
+
+	switch lr.operator {
+	case labelOperatorEquals:
+		return ok && val == lr.value
+	case labelOperatorNotEquals:
+		return !ok || val != lr.value
+	case labelOperatorExists:
+		return ok
+	case labelOperatorNotExists:
+		return !ok
+	}
+
+	return false
+}
+
+// labelSelector selects labelled resources by a comma-separated list of requirements, which must all be met. A
+// requirement is either "key=value", "key!=value", "key" (the label exists) or "!key" (the label does not exist). Like
+// in Kubernetes, "key!=value" also matches resources without the label.
+type labelSelector []labelRequirement
+
+func parseLabelSelector(selector string) (labelSelector, error) {
+	ls := make(labelSelector, 0)
+
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var lr labelRequirement
+		switch {
+		case strings.Contains(term, labelOperatorNotEquals):
+			split := strings.SplitN(term, labelOperatorNotEquals, 2)
+			lr = labelRequirement{key: split[0], operator: labelOperatorNotEquals, value: split[1]}
+		case strings.Contains(term, labelOperatorEquals):
+			split := strings.SplitN(term, labelOperatorEquals, 2)
+			lr = labelRequirement{key: split[0], operator: labelOperatorEquals, value: split[1]}
+		case strings.HasPrefix(term, "!"):
+			lr = labelRequirement{key: strings.TrimPrefix(term, "!"), operator: labelOperatorNotExists}
+		default:
+			lr = labelRequirement{key: term, operator: labelOperatorExists}
+		}
+
+		lr.key = strings.TrimSpace(lr.key)
+		lr.value = strings.TrimSpace(lr.value)
+
+		if lr.key == "" {
+			return nil, errors.Errorf("invalid label selector %q: requirement %q has no key", selector, term)
+		}
+
+		ls = append(ls, lr)
+	}
+
+	return ls, nil
+}
+
+func (ls labelSelector) matches(labels map[string]string) bool {
+	for _, lr := range ls {
+		if !lr.matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,232 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	encodingText   = "text"
+	encodingBase64 = "base64"
+	encodingJSON   = "json"
+)
+
+// publishMessageRequest is the structured alternative to publishing a raw request body. Data can either be a JSON
+// string, which is published as is (or decoded first when Encoding is "base64"), or any other JSON value, which is
+// published as JSON. When Encoding is "json" data is encoded as binary protobuf on topics with a protobuf message type.
+type publishMessageRequest struct {
+	Data        json.RawMessage   `json:"data"`
+	Encoding    string            `json:"encoding,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+func (req publishMessageRequest) data(md protoreflect.MessageDescriptor) ([]byte, error) {
+	switch req.Encoding {
+	case "", encodingText, encodingBase64, encodingJSON:
+	default:
+		return nil, errors.Errorf("unsupported encoding %q", req.Encoding)
+	}
+
+	if req.Encoding == encodingJSON {
+		return encodeJSONData(md, req.Data)
+	}
+
+	if len(req.Data) == 0 || req.Data[0] != '"' {
+		if req.Encoding == encodingBase64 {
+			return nil, errors.New("base64 encoded data must be a string")
+		}
+
+		return req.Data, nil
+	}
+
+	var str string
+	err := json.Unmarshal(req.Data, &str)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode data string")
+	}
+
+	if req.Encoding == encodingBase64 {
+		bts, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode base64 data")
+		}
+
+		return bts, nil
+	}
+
+	return []byte(str), nil
+}
+
+func (req publishMessageRequest) message(md protoreflect.MessageDescriptor) (*pubsub.Message, error) {
+	data, err := req.data(md)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pubsub.Message{
+		Data:        data,
+		Attributes:  req.Attributes,
+		OrderingKey: req.OrderingKey,
+	}, nil
+}
+
+// encodeJSONData prepares JSON data for publishing, which is only encoded as binary protobuf when the topic has a
+// protobuf message type. A nil message descriptor means it has none, in which case the JSON is published as is.
+func encodeJSONData(md protoreflect.MessageDescriptor, data []byte) ([]byte, error) {
+	if md == nil {
+		if !json.Valid(data) {
+			return nil, errors.New("data is not valid JSON")
+		}
+
+		return data, nil
+	}
+
+	return encodeProtobuf(md, data)
+}
+
+// publishMessage publishes the message and waits for the result. Messages with an ordering key can only be published
+// when message ordering was enabled on the topic before its first publish. After a failure publishing is resumed for
+// the ordering key, so later messages with the same key published on the same topic are not rejected. This matters
+// when the topic is used for more than one message, like when redriving.
+func publishMessage(ctx context.Context, topic *pubsub.Topic, msg *pubsub.Message) (string, error) {
+	id, err := topic.Publish(ctx, msg).Get(ctx)
+	if err != nil {
+		if msg.OrderingKey != "" {
+			topic.ResumePublish(msg.OrderingKey)
+		}
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+// decodePublishMessageRequests decodes either a JSON array of publish message requests or newline delimited JSON,
+// with one publish message request per line.
+func decodePublishMessageRequests(rdr io.Reader) ([]publishMessageRequest, error) {
+	br := bufio.NewReader(rdr)
+
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no messages provided")
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read messages")
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			first = b
+			break
+		}
+	}
+	br.UnreadByte()
+
+	dec := json.NewDecoder(br)
+
+	if first == '[' {
+		var reqs []publishMessageRequest
+		err := dec.Decode(&reqs)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode JSON array of messages")
+		}
+
+		return reqs, nil
+	}
+
+	reqs := make([]publishMessageRequest, 0)
+	for {
+		var req publishMessageRequest
+		err := dec.Decode(&req)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decode message %d", len(reqs))
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
+
+type publishResult struct {
+	Index     int    `json:"index"`
+	MessageID string `json:"messageId,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// publishMessages publishes all messages at once, so the topic's publish settings determine how they are batched, and
+// then waits for every individual result. The message descriptor is the protobuf message type of the topic, if any.
+func publishMessages(
+	ctx context.Context,
+	topic *pubsub.Topic,
+	md protoreflect.MessageDescriptor,
+	reqs []publishMessageRequest,
+) []publishResult {
+	results := make([]publishResult, len(reqs))
+	pending := make([]*pubsub.PublishResult, len(reqs))
+
+	for _, req := range reqs {
+		if req.OrderingKey != "" {
+			topic.EnableMessageOrdering = true
+			break
+		}
+	}
+
+	for i, req := range reqs {
+		results[i].Index = i
+
+		msg, err := req.message(md)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		pending[i] = topic.Publish(ctx, msg)
+	}
+
+	for i, res := range pending {
+		if res == nil {
+			continue
+		}
+
+		id, err := res.Get(ctx)
+		if err != nil {
+			results[i].Error = err.Error()
+
+			if key := reqs[i].OrderingKey; key != "" {
+				topic.ResumePublish(key)
+			}
+
+			continue
+		}
+
+		results[i].MessageID = id
+	}
+
+	return results
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newTestClient(t *testing.T) *pubsub.Client {
+	t.Helper()
+
+	ctx := context.Background()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() {
+		srv.Close()
+	})
+
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("could not dial fake Pub/Sub server: %v", err)
+	}
+
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	return client
+}
+
+func TestPublishMessageRequestData(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		encoding string
+		want     string
+		wantErr  bool
+	}{
+		{name: "string", data: `"hello"`, want: "hello"},
+		{name: "text string", data: `"hello"`, encoding: encodingText, want: "hello"},
+		{name: "object", data: `{"hello":"world"}`, want: `{"hello":"world"}`},
+		{name: "base64 string", data: `"aGVsbG8="`, encoding: encodingBase64, want: "hello"},
+		{name: "invalid base64 string", data: `"not base64!"`, encoding: encodingBase64, wantErr: true},
+		{name: "base64 object", data: `{"hello":"world"}`, encoding: encodingBase64, wantErr: true},
+		{name: "json object", data: `{"hello":"world"}`, encoding: encodingJSON, want: `{"hello":"world"}`},
+		{name: "unknown encoding string", data: `"hello"`, encoding: "foo", wantErr: true},
+		{name: "unknown encoding object", data: `{"hello":"world"}`, encoding: "foo", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := publishMessageRequest{
+				Data:     json.RawMessage(tt.data),
+				Encoding: tt.encoding,
+			}
+
+			got, err := req.data(nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got data %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got data %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishMessageResumesOrderingKeyAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	topic := client.Topic("not-yet-created")
+	topic.EnableMessageOrdering = true
+	defer topic.Stop()
+
+	msg := &pubsub.Message{
+		Data:        []byte("hello"),
+		OrderingKey: "key",
+	}
+
+	_, err := publishMessage(ctx, topic, msg)
+	if err == nil {
+		t.Fatal("expected publishing to a missing topic to fail")
+	}
+
+	_, err = client.CreateTopic(ctx, "not-yet-created")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+
+	id, err := publishMessage(ctx, topic, msg)
+	if err != nil {
+		t.Fatalf("expected publishing for the ordering key to be resumed, got: %v", err)
+	}
+	if id == "" {
+		t.Error("expected a message ID")
+	}
+}
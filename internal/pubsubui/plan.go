@@ -0,0 +1,278 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	planActionCreate = "create"
+	planActionUpdate = "update"
+	planActionDelete = "delete"
+)
+
+const (
+	planKindSchema       = "schema"
+	planKindTopic        = "topic"
+	planKindSubscription = "subscription"
+)
+
+type PlannedChange struct {
+	Action         string   `json:"action"`
+	Kind           string   `json:"kind"`
+	ProjectID      string   `json:"projectId"`
+	SchemaID       string   `json:"schemaId,omitempty"`
+	TopicID        string   `json:"topicId,omitempty"`
+	SubscriptionID string   `json:"subscriptionId,omitempty"`
+	Fields         []string `json:"fields,omitempty"`
+}
+
+func (pc PlannedChange) String() string {
+	sb := strings.Builder{}
+
+	sb.WriteString(pc.Action)
+	sb.WriteString(" ")
+	sb.WriteString(pc.Kind)
+
+	switch pc.Kind {
+	case planKindSchema:
+		sb.WriteString(" \"" + pc.SchemaID + "\"")
+	case planKindSubscription:
+		sb.WriteString(" \"" + pc.SubscriptionID + "\"")
+		if pc.TopicID != "" {
+			sb.WriteString(" for topic \"" + pc.TopicID + "\"")
+		}
+	default:
+		sb.WriteString(" \"" + pc.TopicID + "\"")
+	}
+
+	sb.WriteString(" in project \"" + pc.ProjectID + "\"")
+
+	if len(pc.Fields) > 0 {
+		sb.WriteString(": " + strings.Join(pc.Fields, ", "))
+	}
+
+	return sb.String()
+}
+
+type Plan struct {
+	Changes []PlannedChange `json:"changes"`
+}
+
+func (p Plan) log() {
+	if len(p.Changes) == 0 {
+		logWithPrefix("plan: no changes")
+		return
+	}
+
+	logWithPrefix("plan: %d changes", len(p.Changes))
+
+	for _, change := range p.Changes {
+		logWithPrefix("plan: %s", change)
+	}
+}
+
+// planSchema only plans the creation of schemas, since existing schemas cannot be updated.
+func planSchema(ctx context.Context, schemaClient *pubsub.SchemaClient, schemaCfg Schema) (*PlannedChange, error) {
+	_, err := schemaClient.Schema(ctx, schemaCfg.Name, pubsub.SchemaViewBasic)
+	if status.Code(err) == codes.NotFound {
+		return &PlannedChange{
+			Action:    planActionCreate,
+			Kind:      planKindSchema,
+			ProjectID: schemaCfg.ProjectID,
+			SchemaID:  schemaCfg.Name,
+		}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"plan: could not get schema %q in project %q",
+			schemaCfg.Name,
+			schemaCfg.ProjectID,
+		)
+	}
+
+	return nil, nil
+}
+
+func planSubscription(
+	ctx context.Context,
+	client *pubsub.Client,
+	topicCfg Topic,
+	subscriptionCfg TopicSubscription,
+	reconcile bool,
+) (*PlannedChange, error) {
+	deployed, err := client.Subscription(subscriptionCfg.Name).Config(ctx)
+	if status.Code(err) == codes.NotFound {
+		return &PlannedChange{
+			Action:         planActionCreate,
+			Kind:           planKindSubscription,
+			ProjectID:      topicCfg.ProjectID,
+			TopicID:        topicCfg.Name,
+			SubscriptionID: subscriptionCfg.Name,
+		}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"plan: could not get config of subscription %q in project %q",
+			subscriptionCfg.Name,
+			topicCfg.ProjectID,
+		)
+	}
+
+	if !reconcile {
+		return nil, nil
+	}
+
+	_, changed := subscriptionUpdate(client, subscriptionCfg.SubscriptionSettings, deployed)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	return &PlannedChange{
+		Action:         planActionUpdate,
+		Kind:           planKindSubscription,
+		ProjectID:      topicCfg.ProjectID,
+		TopicID:        topicCfg.Name,
+		SubscriptionID: subscriptionCfg.Name,
+		Fields:         changed,
+	}, nil
+}
+
+func planTopic(ctx context.Context, client *pubsub.Client, topicCfg Topic, reconcile bool) ([]PlannedChange, error) {
+	changes := make([]PlannedChange, 0)
+
+	deployed, err := client.Topic(topicCfg.Name).Config(ctx)
+	if status.Code(err) == codes.NotFound {
+		changes = append(changes, PlannedChange{
+			Action:    planActionCreate,
+			Kind:      planKindTopic,
+			ProjectID: topicCfg.ProjectID,
+			TopicID:   topicCfg.Name,
+		})
+	} else if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"plan: could not get config of topic %q in project %q",
+			topicCfg.Name,
+			topicCfg.ProjectID,
+		)
+	} else if reconcile && !stringMapsEqual(topicLabels(topicCfg, deployed.Labels), deployed.Labels) {
+		changes = append(changes, PlannedChange{
+			Action:    planActionUpdate,
+			Kind:      planKindTopic,
+			ProjectID: topicCfg.ProjectID,
+			TopicID:   topicCfg.Name,
+			Fields:    []string{"labels"},
+		})
+	}
+
+	for _, subCfg := range topicCfg.Subscriptions {
+		change, err := planSubscription(ctx, client, topicCfg, subCfg, reconcile)
+		if err != nil {
+			return nil, err
+		}
+
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	return changes, nil
+}
+
+// planTopics determines what creating (or reconciling and pruning) the schemas and topics in the config file would
+// change, without changing anything.
+func planTopics(
+	ctx context.Context,
+	clients map[string]*pubsub.Client,
+	schemaClients map[string]*pubsub.SchemaClient,
+	topics Topics,
+	reconcile bool,
+	prune bool,
+) (Plan, error) {
+	plan := Plan{
+		Changes: make([]PlannedChange, 0),
+	}
+
+	for _, schemaCfg := range topics.Schemas {
+		schemaClient, ok := schemaClients[schemaCfg.ProjectID]
+		if !ok {
+			return Plan{}, errors.Errorf("no schema client configured for project %q", schemaCfg.ProjectID)
+		}
+
+		change, err := planSchema(ctx, schemaClient, schemaCfg)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		if change != nil {
+			plan.Changes = append(plan.Changes, *change)
+		}
+	}
+
+	for _, topicCfg := range topics.Topics {
+		client, ok := clients[topicCfg.ProjectID]
+		if !ok {
+			return Plan{}, errors.Errorf("no client configured for project %q", topicCfg.ProjectID)
+		}
+
+		changes, err := planTopic(ctx, client, topicCfg, reconcile)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		plan.Changes = append(plan.Changes, changes...)
+	}
+
+	if !reconcile || !prune {
+		return plan, nil
+	}
+
+	for _, projectID := range prunableProjectIDs(clients) {
+		subIDs, topicIDs, err := prunable(ctx, clients[projectID], projectID, topics)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		for _, subID := range subIDs {
+			plan.Changes = append(plan.Changes, PlannedChange{
+				Action:         planActionDelete,
+				Kind:           planKindSubscription,
+				ProjectID:      projectID,
+				SubscriptionID: subID,
+			})
+		}
+
+		for _, topicID := range topicIDs {
+			plan.Changes = append(plan.Changes, PlannedChange{
+				Action:    planActionDelete,
+				Kind:      planKindTopic,
+				ProjectID: projectID,
+				TopicID:   topicID,
+			})
+		}
+	}
+
+	return plan, nil
+}
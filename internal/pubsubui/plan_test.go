@@ -0,0 +1,62 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestPlanWithoutClientIsBadRequest(t *testing.T) {
+	srv := &Server{
+		topicsCache: newTopicsCache(time.Minute),
+	}
+	srv.reload(nil, map[string]*pubsub.Client{}, nil, nil, Topics{
+		Topics: []Topic{{Name: "my-topic", ProjectID: "unknown-project"}},
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Plan(rec, httptest.NewRequest(http.MethodGet, "/api/plan", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+}
+
+func TestPlanCreatesMissingTopics(t *testing.T) {
+	client := newTestClient(t)
+
+	srv := &Server{
+		topicsCache: newTopicsCache(time.Minute),
+	}
+	srv.reload(nil, map[string]*pubsub.Client{"test-project": client}, nil, nil, Topics{
+		Topics: []Topic{{
+			Name:          "my-topic",
+			ProjectID:     "test-project",
+			Subscriptions: []TopicSubscription{{Name: "my-subscription"}},
+		}},
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Plan(rec, httptest.NewRequest(http.MethodGet, "/api/plan", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// TopicProtobuf declares the protobuf message type of the messages on a topic without a Pub/Sub schema. The
+// descriptor set file is a binary FileDescriptorSet, as written by `protoc --include_imports --descriptor_set_out`.
+type TopicProtobuf struct {
+	DescriptorSetFile string `yaml:"descriptorSetFile"`
+	MessageType       string `yaml:"messageType"`
+
+	descriptor protoreflect.MessageDescriptor
+}
+
+func (tp TopicProtobuf) validate() error {
+	if tp.DescriptorSetFile == "" {
+		return errors.New("protobuf has no descriptor set file")
+	}
+
+	if tp.MessageType == "" {
+		return errors.New("protobuf has no message type")
+	}
+
+	return nil
+}
+
+// parseDescriptorSet finds the message type in a binary FileDescriptorSet.
+func parseDescriptorSet(bts []byte, messageType string) (protoreflect.MessageDescriptor, error) {
+	if messageType == "" {
+		return nil, errors.New("no message type provided")
+	}
+
+	var fdset descriptorpb.FileDescriptorSet
+	err := proto.Unmarshal(bts, &fdset)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode file descriptor set")
+	}
+
+	return messageDescriptor(&fdset, messageType)
+}
+
+// loadProtobufDescriptors reads the descriptor set files of the topics which declare a protobuf message type. Relative
+// paths are resolved against baseDir, the directory of the config file.
+func (ts *Topics) loadProtobufDescriptors(baseDir string) error {
+	for i, topic := range ts.Topics {
+		if topic.Protobuf == nil {
+			continue
+		}
+
+		path := topic.Protobuf.DescriptorSetFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		bts, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "could not read descriptor set file of topic %q", topic.Name)
+		}
+
+		md, err := parseDescriptorSet(bts, topic.Protobuf.MessageType)
+		if err != nil {
+			return errors.Wrapf(err, "invalid descriptor set file of topic %q", topic.Name)
+		}
+
+		ts.Topics[i].Protobuf.descriptor = md
+	}
+
+	return nil
+}
+
+func (ts Topics) ProtobufTypes() map[string]protoreflect.MessageDescriptor {
+	protobufTypes := make(map[string]protoreflect.MessageDescriptor)
+
+	for _, topic := range ts.Topics {
+		if topic.Protobuf != nil && topic.Protobuf.descriptor != nil {
+			protobufTypes[topic.Key()] = topic.Protobuf.descriptor
+		}
+	}
+
+	return protobufTypes
+}
+
+// encodeProtobuf encodes a message in the protobuf JSON format as binary protobuf.
+func encodeProtobuf(md protoreflect.MessageDescriptor, data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(md)
+
+	err := protojson.Unmarshal(data, msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decode JSON as %q", md.FullName())
+	}
+
+	bts, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not encode %q as protobuf", md.FullName())
+	}
+
+	return bts, nil
+}
@@ -0,0 +1,398 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+// subscriptionUpdate determines which of the mutable settings of a deployed subscription differ from the settings in
+// the config file. Only settings which are explicitly configured are compared, so a subscription which is only
+// declared by name is left as is.
+func subscriptionUpdate(
+	client *pubsub.Client,
+	desired SubscriptionSettings,
+	deployed pubsub.SubscriptionConfig,
+) (pubsub.SubscriptionConfigToUpdate, []string) {
+	var update pubsub.SubscriptionConfigToUpdate
+	changed := make([]string, 0)
+
+	if desired.AckDeadline != 0 && time.Duration(desired.AckDeadline) != deployed.AckDeadline {
+		update.AckDeadline = time.Duration(desired.AckDeadline)
+		changed = append(changed, "ackDeadline")
+	}
+
+	if desired.RetentionDuration != 0 && time.Duration(desired.RetentionDuration) != deployed.RetentionDuration {
+		update.RetentionDuration = time.Duration(desired.RetentionDuration)
+		changed = append(changed, "retentionDuration")
+	}
+
+	if ram := desired.RetainAckedMessages; ram != nil && *ram != deployed.RetainAckedMessages {
+		update.RetainAckedMessages = *ram
+		changed = append(changed, "retainAckedMessages")
+	}
+
+	if eod := desired.EnableExactlyOnceDelivery; eod != nil && *eod != deployed.EnableExactlyOnceDelivery {
+		update.EnableExactlyOnceDelivery = *eod
+		changed = append(changed, "enableExactlyOnceDelivery")
+	}
+
+	if desired.ExpirationPolicy != nil && *desired.ExpirationPolicy != optionalDuration(deployed.ExpirationPolicy) {
+		update.ExpirationPolicy = time.Duration(*desired.ExpirationPolicy)
+		changed = append(changed, "expirationPolicy")
+	}
+
+	desiredCfg := desired.subscriptionConfig(client, deployed.Topic)
+
+	if dlp := desiredCfg.DeadLetterPolicy; dlp != nil {
+		if deployed.DeadLetterPolicy == nil || *dlp != *deployed.DeadLetterPolicy {
+			update.DeadLetterPolicy = dlp
+			changed = append(changed, "deadLetterPolicy")
+		}
+	}
+
+	if rp := desired.RetryPolicy; rp != nil {
+		var deployedRP RetryPolicy
+		if deployed.RetryPolicy != nil {
+			deployedRP.MinimumBackoff = optionalDuration(deployed.RetryPolicy.MinimumBackoff)
+			deployedRP.MaximumBackoff = optionalDuration(deployed.RetryPolicy.MaximumBackoff)
+		}
+
+		if (rp.MinimumBackoff != 0 && rp.MinimumBackoff != deployedRP.MinimumBackoff) ||
+			(rp.MaximumBackoff != 0 && rp.MaximumBackoff != deployedRP.MaximumBackoff) {
+			update.RetryPolicy = desiredCfg.RetryPolicy
+			changed = append(changed, "retryPolicy")
+		}
+	}
+
+	if desired.PushEndpoint != "" {
+		if desired.PushEndpoint != deployed.PushConfig.Endpoint ||
+			!stringMapsEqual(desired.PushAttributes, deployed.PushConfig.Attributes) {
+			update.PushConfig = &desiredCfg.PushConfig
+			changed = append(changed, "pushConfig")
+		}
+	}
+
+	// Labels which are not in the config file are left alone, unless labels are configured explicitly. The managed
+	// label is only kept, existing subscriptions which were not created by pubsubui are not adopted.
+	desiredLabels := deployed.Labels
+	if desired.Labels != nil {
+		desiredLabels = withReservedLabels(desired.Labels, deployed.Labels)
+	}
+	if !stringMapsEqual(desiredLabels, deployed.Labels) {
+		update.Labels = desiredLabels
+		changed = append(changed, "labels")
+	}
+
+	return update, changed
+}
+
+func reconcileSubscription(
+	ctx context.Context,
+	client *pubsub.Client,
+	projectID string,
+	topicName string,
+	subscriptionCfg TopicSubscription,
+) error {
+	sub := client.Subscription(subscriptionCfg.Name)
+
+	deployed, err := sub.Config(ctx)
+	if err != nil {
+		return errors.Wrapf(
+			err,
+			"subscription: could not get config of %q for topic %q in project %q",
+			subscriptionCfg.Name,
+			topicName,
+			projectID,
+		)
+	}
+
+	// Filters and message ordering cannot be changed after creation, so the best we can do is report the drift.
+	if f := subscriptionCfg.Filter; f != nil && *f != deployed.Filter {
+		logWithPrefix(
+			"subscription: reconciling: filter of %q in project %q differs but cannot be updated",
+			subscriptionCfg.Name,
+			projectID,
+		)
+	}
+	if emo := subscriptionCfg.EnableMessageOrdering; emo != nil && *emo != deployed.EnableMessageOrdering {
+		logWithPrefix(
+			"subscription: reconciling: message ordering of %q in project %q differs but cannot be updated",
+			subscriptionCfg.Name,
+			projectID,
+		)
+	}
+
+	update, changed := subscriptionUpdate(client, subscriptionCfg.SubscriptionSettings, deployed)
+	if len(changed) == 0 {
+		logWithPrefix("subscription: up to date: %q in project %q", subscriptionCfg.Name, projectID)
+		return nil
+	}
+
+	_, err = sub.Update(ctx, update)
+	if err != nil {
+		return errors.Wrapf(
+			err,
+			"subscription: could not update %s of %q in project %q",
+			strings.Join(changed, ", "),
+			subscriptionCfg.Name,
+			projectID,
+		)
+	}
+
+	logWithPrefix(
+		"subscription: updated %s of %q in project %q",
+		strings.Join(changed, ", "),
+		subscriptionCfg.Name,
+		projectID,
+	)
+
+	return nil
+}
+
+// topicLabels determines the labels a deployed topic should have. Just like with subscriptions, labels which are not in
+// the config file are left alone, unless labels are configured explicitly, and topics are never adopted.
+func topicLabels(topicCfg Topic, deployed map[string]string) map[string]string {
+	if topicCfg.Labels == nil {
+		return deployed
+	}
+
+	return withReservedLabels(topicCfg.Labels, deployed)
+}
+
+func reconcileTopic(ctx context.Context, client *pubsub.Client, topicCfg Topic) error {
+	topic := client.Topic(topicCfg.Name)
+
+	deployed, err := topic.Config(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "topic: could not get config of %q in project %q", topicCfg.Name, topicCfg.ProjectID)
+	}
+
+	desiredLabels := topicLabels(topicCfg, deployed.Labels)
+	if !stringMapsEqual(desiredLabels, deployed.Labels) {
+		_, err = topic.Update(ctx, pubsub.TopicConfigToUpdate{
+			Labels: desiredLabels,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "topic: could not update %q in project %q", topicCfg.Name, topicCfg.ProjectID)
+		}
+
+		logWithPrefix("topic: updated labels of %q in project %q", topicCfg.Name, topicCfg.ProjectID)
+	} else {
+		logWithPrefix("topic: up to date: %q in project %q", topicCfg.Name, topicCfg.ProjectID)
+	}
+
+	// The schema of a topic cannot be changed after creation, so the best we can do is report the drift.
+	if topicCfg.Schema != nil {
+		desiredSchema, err := topicCfg.Schema.schemaSettings(topicCfg.ProjectID)
+		if err != nil {
+			return errors.Wrapf(err, "topic: could not reconcile %q in project %q", topicCfg.Name, topicCfg.ProjectID)
+		}
+
+		if deployed.SchemaSettings == nil || *deployed.SchemaSettings != *desiredSchema {
+			logWithPrefix(
+				"topic: reconciling: schema of %q in project %q differs but cannot be updated",
+				topicCfg.Name,
+				topicCfg.ProjectID,
+			)
+		}
+	}
+
+	sg := errgroup.Group{}
+	for _, sc := range topicCfg.Subscriptions {
+		subCfg := sc
+
+		sg.Go(func() error {
+			return reconcileSubscription(ctx, client, topicCfg.ProjectID, topicCfg.Name, subCfg)
+		})
+	}
+
+	return sg.Wait()
+}
+
+// prunable returns the IDs of all subscriptions and topics in the project of the client which carry the managed
+// label but are no longer declared in the config file.
+func prunable(ctx context.Context, client *pubsub.Client, projectID string, topics Topics) ([]string, []string, error) {
+	declaredTopics := make(map[string]bool)
+	declaredSubscriptions := make(map[string]bool)
+	for _, topic := range topics.Topics {
+		if topic.ProjectID != projectID {
+			continue
+		}
+
+		declaredTopics[topic.Name] = true
+		for _, sub := range topic.Subscriptions {
+			declaredSubscriptions[sub.Name] = true
+		}
+	}
+
+	subIDs := make([]string, 0)
+	subIt := client.Subscriptions(ctx)
+	for {
+		cfg, err := subIt.NextConfig()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "prune: could not list subscriptions in project %q", projectID)
+		}
+
+		if cfg.Labels[labelKeyManaged] == labelValueManaged && !declaredSubscriptions[cfg.ID()] {
+			subIDs = append(subIDs, cfg.ID())
+		}
+	}
+
+	topicIDs := make([]string, 0)
+	topicIt := client.Topics(ctx)
+	for {
+		topic, err := topicIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "prune: could not list topics in project %q", projectID)
+		}
+
+		if declaredTopics[topic.ID()] {
+			continue
+		}
+
+		cfg, err := topic.Config(ctx)
+		if err != nil {
+			return nil, nil, errors.Wrapf(
+				err,
+				"prune: could not get config of topic %q in project %q",
+				topic.ID(),
+				projectID,
+			)
+		}
+
+		if cfg.Labels[labelKeyManaged] == labelValueManaged {
+			topicIDs = append(topicIDs, topic.ID())
+		}
+	}
+
+	return subIDs, topicIDs, nil
+}
+
+// pruneProject deletes all topics and subscriptions in the project of the client which carry the managed label but
+// are no longer declared in the config file.
+func pruneProject(ctx context.Context, client *pubsub.Client, projectID string, topics Topics) error {
+	subIDs, topicIDs, err := prunable(ctx, client, projectID, topics)
+	if err != nil {
+		return err
+	}
+
+	for _, subID := range subIDs {
+		err = client.Subscription(subID).Delete(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "prune: could not delete subscription %q in project %q", subID, projectID)
+		}
+
+		logWithPrefix("prune: deleted subscription %q in project %q", subID, projectID)
+	}
+
+	for _, topicID := range topicIDs {
+		err = client.Topic(topicID).Delete(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "prune: could not delete topic %q in project %q", topicID, projectID)
+		}
+
+		logWithPrefix("prune: deleted topic %q in project %q", topicID, projectID)
+	}
+
+	return nil
+}
+
+// checkClients returns an error when a project in the config file has no client, so this is known before anything is
+// changed.
+func checkClients(clients map[string]*pubsub.Client, topics Topics) error {
+	for _, projectID := range topics.ProjectIDs() {
+		if _, ok := clients[projectID]; !ok {
+			return errors.Errorf("no client configured for project %q", projectID)
+		}
+	}
+
+	return nil
+}
+
+// prunableProjectIDs returns the projects to prune, which are all projects with a client. Next to the projects in the
+// config file, these are the projects configured explicitly and the projects which were in the config file before it
+// was reloaded, any of which may still hold managed topics and subscriptions.
+func prunableProjectIDs(clients map[string]*pubsub.Client) []string {
+	projectIDs := make([]string, 0, len(clients))
+	for projectID := range clients {
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	sort.Strings(projectIDs)
+
+	return projectIDs
+}
+
+// reconcileTopics creates the topics and subscriptions in the config file which do not exist yet, updates the ones
+// which do exist to match the config file and, when prune is set, deletes the managed ones which are no longer in it.
+func reconcileTopics(ctx context.Context, clients map[string]*pubsub.Client, topics Topics, prune bool) error {
+	err := checkClients(clients, topics)
+	if err != nil {
+		return errors.Wrap(err, "topics: could not reconcile")
+	}
+
+	err = createTopics(ctx, clients, topics)
+	if err != nil {
+		return err
+	}
+
+	logWithPrefix("topics: reconciling %d topics from config file", len(topics.Topics))
+
+	tg := errgroup.Group{}
+	for _, tcfg := range topics.Topics {
+		topicCfg := tcfg
+		client := clients[topicCfg.ProjectID]
+
+		tg.Go(func() error {
+			return reconcileTopic(ctx, client, topicCfg)
+		})
+	}
+	err = tg.Wait()
+	if err != nil {
+		return errors.Wrap(err, "topics: could not reconcile")
+	}
+
+	logWithPrefix("topics: all %d topics reconciled", len(topics.Topics))
+
+	if !prune {
+		return nil
+	}
+
+	for _, projectID := range prunableProjectIDs(clients) {
+		err = pruneProject(ctx, clients[projectID], projectID, topics)
+		if err != nil {
+			return errors.Wrap(err, "topics: could not prune")
+		}
+	}
+
+	logWithPrefix("topics: pruned all configured projects")
+
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestSubscriptionUpdate(t *testing.T) {
+	yes, no := true, false
+	ackDeadline := Duration(time.Second * 30)
+
+	deployed := pubsub.SubscriptionConfig{
+		AckDeadline:               time.Second * 10,
+		RetainAckedMessages:       true,
+		EnableExactlyOnceDelivery: true,
+		Labels: map[string]string{
+			"team":          "a",
+			labelKeyManaged: labelValueManaged,
+		},
+	}
+
+	tests := []struct {
+		name        string
+		desired     SubscriptionSettings
+		wantChanged []string
+		wantLabels  map[string]string
+	}{
+		{
+			name:        "declared by name only",
+			desired:     SubscriptionSettings{},
+			wantChanged: []string{},
+		},
+		{
+			name: "same values",
+			desired: SubscriptionSettings{
+				RetainAckedMessages:       &yes,
+				EnableExactlyOnceDelivery: &yes,
+			},
+			wantChanged: []string{},
+		},
+		{
+			name: "explicitly disabled",
+			desired: SubscriptionSettings{
+				RetainAckedMessages:       &no,
+				EnableExactlyOnceDelivery: &no,
+			},
+			wantChanged: []string{"retainAckedMessages", "enableExactlyOnceDelivery"},
+		},
+		{
+			name: "ack deadline",
+			desired: SubscriptionSettings{
+				AckDeadline: ackDeadline,
+			},
+			wantChanged: []string{"ackDeadline"},
+		},
+		{
+			name: "labels keep the managed label",
+			desired: SubscriptionSettings{
+				Labels: map[string]string{"team": "b"},
+			},
+			wantChanged: []string{"labels"},
+			wantLabels: map[string]string{
+				"team":          "b",
+				labelKeyManaged: labelValueManaged,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, changed := subscriptionUpdate(nil, tt.desired, deployed)
+
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("got changed %v, want %v", changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(update.Labels, tt.wantLabels) {
+				t.Errorf("got labels %v, want %v", update.Labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestTopicLabels(t *testing.T) {
+	deployed := map[string]string{
+		"team":          "a",
+		labelKeyManaged: labelValueManaged,
+	}
+
+	got := topicLabels(Topic{}, deployed)
+	if !reflect.DeepEqual(got, deployed) {
+		t.Errorf("without configured labels got %v, want %v", got, deployed)
+	}
+
+	got = topicLabels(Topic{Labels: map[string]string{"team": "b"}}, deployed)
+	want := map[string]string{
+		"team":          "b",
+		labelKeyManaged: labelValueManaged,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with configured labels got %v, want %v", got, want)
+	}
+
+	got = topicLabels(Topic{Labels: map[string]string{"team": "b"}}, map[string]string{"team": "a"})
+	if _, ok := got[labelKeyManaged]; ok {
+		t.Errorf("topics which were not created by pubsubui must not be adopted, got %v", got)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var timeoutSchemaCreation = time.Second * 15
+
+const (
+	schemaTypeAvro     = "avro"
+	schemaTypeProtobuf = "protobuf"
+)
+
+const (
+	schemaEncodingJSON   = "json"
+	schemaEncodingBinary = "binary"
+)
+
+// Schema is a Pub/Sub schema, either declared in the config file or returned by the API. In the config file the
+// definition can also be read from a file, relative to the config file.
+type Schema struct {
+	Name           string `yaml:"name"           json:"name"`
+	ProjectID      string `yaml:"project"        json:"projectId"`
+	Type           string `yaml:"type"           json:"type"`
+	Definition     string `yaml:"definition"     json:"definition,omitempty"`
+	DefinitionFile string `yaml:"definitionFile" json:"-"`
+}
+
+func (s Schema) Key() string {
+	return s.ProjectID + "/" + s.Name
+}
+
+func (s Schema) validate() error {
+	if s.Name == "" {
+		return errors.New("schema has no name")
+	}
+
+	if s.ProjectID == "" {
+		return errors.Errorf("schema %q has no project", s.Name)
+	}
+
+	_, err := schemaType(s.Type)
+	if err != nil {
+		return errors.Wrapf(err, "invalid schema %q", s.Name)
+	}
+
+	if (s.Definition == "") == (s.DefinitionFile == "") {
+		return errors.Errorf("schema %q needs either a definition or a definition file", s.Name)
+	}
+
+	return nil
+}
+
+func (s Schema) schemaConfig() (pubsub.SchemaConfig, error) {
+	st, err := schemaType(s.Type)
+	if err != nil {
+		return pubsub.SchemaConfig{}, err
+	}
+
+	return pubsub.SchemaConfig{
+		Type:       st,
+		Definition: s.Definition,
+	}, nil
+}
+
+func schemaFromConfig(projectID string, cfg pubsub.SchemaConfig) Schema {
+	return Schema{
+		Name:       topicNameFromTopicID(cfg.Name),
+		ProjectID:  projectID,
+		Type:       schemaTypeName(cfg.Type),
+		Definition: cfg.Definition,
+	}
+}
+
+// TopicSchema binds a topic to a schema. The name is either the ID of a schema in the project of the topic or the
+// fully qualified name of a schema.
+type TopicSchema struct {
+	Name     string `yaml:"name"     json:"name"`
+	Encoding string `yaml:"encoding" json:"encoding"`
+}
+
+func (ts TopicSchema) validate() error {
+	if ts.Name == "" {
+		return errors.New("schema has no name")
+	}
+
+	_, err := schemaEncoding(ts.Encoding)
+
+	return err
+}
+
+func (ts TopicSchema) schemaSettings(projectID string) (*pubsub.SchemaSettings, error) {
+	encoding, err := schemaEncoding(ts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pubsub.SchemaSettings{
+		Schema:   schemaPath(projectID, ts.Name),
+		Encoding: encoding,
+	}, nil
+}
+
+func topicSchemaFromSettings(ss *pubsub.SchemaSettings) *TopicSchema {
+	if ss == nil {
+		return nil
+	}
+
+	return &TopicSchema{
+		Name:     topicNameFromTopicID(ss.Schema),
+		Encoding: schemaEncodingName(ss.Encoding),
+	}
+}
+
+// validateMessageRequest holds a sample message to validate against a schema. With the JSON encoding the data is
+// either a JSON string holding the message or the message itself as a JSON value. With the binary encoding the data
+// must be a base64 encoded JSON string.
+type validateMessageRequest struct {
+	Data     json.RawMessage `json:"data"`
+	Encoding string          `json:"encoding,omitempty"`
+}
+
+func (req validateMessageRequest) message() ([]byte, pubsub.SchemaEncoding, error) {
+	encoding, err := schemaEncoding(req.Encoding)
+	if err != nil {
+		return nil, pubsub.EncodingUnspecified, err
+	}
+
+	dataEncoding := encodingText
+	if encoding == pubsub.EncodingBinary {
+		dataEncoding = encodingBase64
+	}
+
+	data, err := publishMessageRequest{Data: req.Data, Encoding: dataEncoding}.data(nil)
+	if err != nil {
+		return nil, pubsub.EncodingUnspecified, err
+	}
+
+	return data, encoding, nil
+}
+
+func schemaPath(projectID, schemaName string) string {
+	if strings.Contains(schemaName, "/") {
+		return schemaName
+	}
+
+	return fmt.Sprintf("projects/%s/schemas/%s", projectID, schemaName)
+}
+
+func schemaType(t string) (pubsub.SchemaType, error) {
+	switch t {
+	case schemaTypeAvro:
+		return pubsub.SchemaAvro, nil
+	case schemaTypeProtobuf:
+		return pubsub.SchemaProtocolBuffer, nil
+	default:
+		return pubsub.SchemaTypeUnspecified, errors.Errorf(
+			"unsupported schema type %q, must be %q or %q",
+			t,
+			schemaTypeAvro,
+			schemaTypeProtobuf,
+		)
+	}
+}
+
+func schemaTypeName(t pubsub.SchemaType) string {
+	switch t {
+	case pubsub.SchemaAvro:
+		return schemaTypeAvro
+	case pubsub.SchemaProtocolBuffer:
+		return schemaTypeProtobuf
+	default:
+		return ""
+	}
+}
+
+// schemaEncoding defaults to JSON, since that is the encoding every schema type supports.
+func schemaEncoding(e string) (pubsub.SchemaEncoding, error) {
+	switch e {
+	case "", schemaEncodingJSON:
+		return pubsub.EncodingJSON, nil
+	case schemaEncodingBinary:
+		return pubsub.EncodingBinary, nil
+	default:
+		return pubsub.EncodingUnspecified, errors.Errorf(
+			"unsupported schema encoding %q, must be %q or %q",
+			e,
+			schemaEncodingJSON,
+			schemaEncodingBinary,
+		)
+	}
+}
+
+func schemaEncodingName(e pubsub.SchemaEncoding) string {
+	switch e {
+	case pubsub.EncodingJSON:
+		return schemaEncodingJSON
+	case pubsub.EncodingBinary:
+		return schemaEncodingBinary
+	default:
+		return ""
+	}
+}
+
+// loadSchemaDefinitions reads the definitions of the schemas which refer to a definition file. Relative paths are
+// resolved against baseDir, the directory of the config file.
+func (ts *Topics) loadSchemaDefinitions(baseDir string) error {
+	for i, schema := range ts.Schemas {
+		if schema.DefinitionFile == "" {
+			continue
+		}
+
+		path := schema.DefinitionFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		bts, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "could not read definition file of schema %q", schema.Name)
+		}
+
+		ts.Schemas[i].Definition = string(bts)
+	}
+
+	return nil
+}
+
+func createSchema(ctx context.Context, schemaClient *pubsub.SchemaClient, schemaCfg Schema) error {
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(timeoutSchemaCreation))
+	defer func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logWithPrefix("schema: creating: timeout on %q in project %q", schemaCfg.Name, schemaCfg.ProjectID)
+		} else {
+			cancel()
+		}
+	}()
+
+	logWithPrefix("schema: creating: %q in project %q", schemaCfg.Name, schemaCfg.ProjectID)
+
+	cfg, err := schemaCfg.schemaConfig()
+	if err != nil {
+		return errors.Wrapf(err, "schema: could not create %q in project %q", schemaCfg.Name, schemaCfg.ProjectID)
+	}
+
+	// Schemas cannot be changed after creation, so the best we can do for an existing schema is report the drift.
+	deployed, err := schemaClient.Schema(ctx, schemaCfg.Name, pubsub.SchemaViewFull)
+	if err == nil {
+		if deployed.Type != cfg.Type || deployed.Definition != cfg.Definition {
+			logWithPrefix(
+				"schema: already exists: %q in project %q differs from the config file but cannot be updated",
+				schemaCfg.Name,
+				schemaCfg.ProjectID,
+			)
+		} else {
+			logWithPrefix("schema: already exists: %q in project %q", schemaCfg.Name, schemaCfg.ProjectID)
+		}
+
+		return nil
+	}
+	if status.Code(err) != codes.NotFound {
+		return errors.Wrapf(err, "schema: could not get %q in project %q", schemaCfg.Name, schemaCfg.ProjectID)
+	}
+
+	_, err = schemaClient.CreateSchema(ctx, schemaCfg.Name, cfg)
+	if err != nil {
+		return errors.Wrapf(err, "schema: could not create %q in project %q", schemaCfg.Name, schemaCfg.ProjectID)
+	}
+
+	logWithPrefix("schema: created: %q in project %q", schemaCfg.Name, schemaCfg.ProjectID)
+
+	return nil
+}
+
+// createSchemas creates the schemas in the config file which do not exist yet. This has to happen before the topics
+// are created, since topics can only be bound to existing schemas.
+func createSchemas(ctx context.Context, schemaClients map[string]*pubsub.SchemaClient, topics Topics) error {
+	if len(topics.Schemas) == 0 {
+		return nil
+	}
+
+	logWithPrefix("schemas: creating %d schemas from config file", len(topics.Schemas))
+
+	sg := errgroup.Group{}
+	for _, scfg := range topics.Schemas {
+		schemaCfg := scfg
+
+		schemaClient, ok := schemaClients[schemaCfg.ProjectID]
+		if !ok {
+			return errors.Errorf("no schema client configured for project %q", schemaCfg.ProjectID)
+		}
+
+		sg.Go(func() error {
+			return createSchema(ctx, schemaClient, schemaCfg)
+		})
+	}
+	err := sg.Wait()
+	if err != nil {
+		return errors.Wrap(err, "schemas: could not create")
+	}
+
+	logWithPrefix("schemas: all %d schemas created", len(topics.Schemas))
+
+	return nil
+}
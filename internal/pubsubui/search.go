@@ -0,0 +1,191 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	queryParamKeyLabels = "labels"
+	queryParamKeyQuery  = "q"
+	queryParamKeyRegex  = "regex"
+	queryParamKeyPrefix = "prefix"
+	queryParamKeySort   = "sort"
+)
+
+const (
+	topicSortNone      = ""
+	topicSortName      = "name"
+	topicSortNameDesc  = "-name"
+	topicSortRelevance = "relevance"
+)
+
+// MatchRange is the part of a topic name matched by a search, as byte offsets into the name. Topic names only hold
+// ASCII characters, so these are also character offsets.
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// topicQuery selects, searches and sorts topics. The search either looks for a substring of the topic name or, when
+// regex is set, for matches of a regular expression. Both are case-insensitive. Without a sort order topics are listed
+// in the order Pub/Sub returns them.
+type topicQuery struct {
+	selector labelSelector
+	prefix   string
+	search   string
+	regex    *regexp.Regexp
+	sort     string
+}
+
+func parseTopicQuery(qry url.Values) (topicQuery, error) {
+	selector, err := parseLabelSelector(qry.Get(queryParamKeyLabels))
+	if err != nil {
+		return topicQuery{}, err
+	}
+
+	tq := topicQuery{
+		selector: selector,
+		prefix:   qry.Get(queryParamKeyPrefix),
+		search:   qry.Get(queryParamKeyQuery),
+		sort:     qry.Get(queryParamKeySort),
+	}
+
+	useRegex, err := strconv.ParseBool(getQueryParamOrDefault(qry, queryParamKeyRegex, "false"))
+	if err != nil {
+		return topicQuery{}, errors.Errorf("invalid regex flag %q", qry.Get(queryParamKeyRegex))
+	}
+
+	if useRegex && tq.search != "" {
+		tq.regex, err = regexp.Compile("(?i)" + tq.search)
+		if err != nil {
+			return topicQuery{}, errors.Wrapf(err, "invalid regular expression %q", tq.search)
+		}
+	}
+
+	switch tq.sort {
+	case topicSortNone, topicSortName, topicSortNameDesc, topicSortRelevance:
+	default:
+		return topicQuery{}, errors.Errorf("invalid sort order %q", tq.sort)
+	}
+
+	// Without a search all topics are equally relevant.
+	if tq.sort == topicSortRelevance && tq.search == "" {
+		tq.sort = topicSortName
+	}
+
+	return tq, nil
+}
+
+// matches returns where the search matches the given name. It returns false when the search does not match at all.
+func (tq topicQuery) matches(name string) ([]MatchRange, bool) {
+	if tq.search == "" {
+		return nil, true
+	}
+
+	ranges := make([]MatchRange, 0)
+
+	if tq.regex != nil {
+		for _, loc := range tq.regex.FindAllStringIndex(name, -1) {
+			// Empty matches, like those of "^", cannot be highlighted.
+			if loc[0] != loc[1] {
+				ranges = append(ranges, MatchRange{Start: loc[0], End: loc[1]})
+			}
+		}
+
+		return ranges, tq.regex.MatchString(name)
+	}
+
+	lowerName := strings.ToLower(name)
+	lowerSearch := strings.ToLower(tq.search)
+
+	for offset := 0; ; {
+		idx := strings.Index(lowerName[offset:], lowerSearch)
+		if idx < 0 {
+			break
+		}
+
+		start := offset + idx
+		offset = start + len(lowerSearch)
+		ranges = append(ranges, MatchRange{Start: start, End: offset})
+	}
+
+	return ranges, len(ranges) > 0
+}
+
+// apply returns the topics selected by the query in the requested order, together with the matches of the search. The
+// given topics are left untouched.
+func (tq topicQuery) apply(topics []Topic) []listedTopic {
+	selected := make([]listedTopic, 0, len(topics))
+
+	for _, topic := range topics {
+		if !strings.HasPrefix(topic.Name, tq.prefix) || !tq.selector.matches(topic.Labels) {
+			continue
+		}
+
+		matches, ok := tq.matches(topic.Name)
+		if !ok {
+			continue
+		}
+
+		selected = append(selected, listedTopic{
+			Topic:   topic,
+			Matches: matches,
+		})
+	}
+
+	switch tq.sort {
+	case topicSortName:
+		sort.SliceStable(selected, func(i, j int) bool {
+			return selected[i].Name < selected[j].Name
+		})
+	case topicSortNameDesc:
+		sort.SliceStable(selected, func(i, j int) bool {
+			return selected[i].Name > selected[j].Name
+		})
+	case topicSortRelevance:
+		// Topics matching earlier in their name rank higher, then shorter names since more of them matches.
+		sort.SliceStable(selected, func(i, j int) bool {
+			a, b := selected[i], selected[j]
+
+			aStart, bStart := firstMatchStart(a), firstMatchStart(b)
+			if aStart != bStart {
+				return aStart < bStart
+			}
+			if len(a.Name) != len(b.Name) {
+				return len(a.Name) < len(b.Name)
+			}
+
+			return a.Name < b.Name
+		})
+	}
+
+	return selected
+}
+
+func firstMatchStart(topic listedTopic) int {
+	if len(topic.Matches) == 0 {
+		return len(topic.Name)
+	}
+
+	return topic.Matches[0].Start
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopicQueryApply(t *testing.T) {
+	topics := []Topic{
+		{Name: "payments-created", Labels: map[string]string{"team": "payments"}},
+		{Name: "orders", Labels: map[string]string{"team": "orders"}},
+		{Name: "orders-created", Labels: map[string]string{"team": "orders", "env": "prod"}},
+		{Name: "Orders-Legacy"},
+	}
+
+	tests := []struct {
+		name        string
+		query       map[string][]string
+		wantNames   []string
+		wantMatches [][]MatchRange
+	}{
+		{
+			name:      "everything in Pub/Sub order",
+			query:     map[string][]string{},
+			wantNames: []string{"payments-created", "orders", "orders-created", "Orders-Legacy"},
+		},
+		{
+			name:      "prefix",
+			query:     map[string][]string{"prefix": {"orders"}},
+			wantNames: []string{"orders", "orders-created"},
+		},
+		{
+			name:      "labels",
+			query:     map[string][]string{"labels": {"team=orders,!env"}},
+			wantNames: []string{"orders"},
+		},
+		{
+			name:      "substring",
+			query:     map[string][]string{"q": {"ORDERS"}},
+			wantNames: []string{"orders", "orders-created", "Orders-Legacy"},
+			wantMatches: [][]MatchRange{
+				{{Start: 0, End: 6}},
+				{{Start: 0, End: 6}},
+				{{Start: 0, End: 6}},
+			},
+		},
+		{
+			name:      "repeated substring",
+			query:     map[string][]string{"q": {"e"}, "prefix": {"orders-"}},
+			wantNames: []string{"orders-created"},
+			wantMatches: [][]MatchRange{
+				{{Start: 3, End: 4}, {Start: 9, End: 10}, {Start: 12, End: 13}},
+			},
+		},
+		{
+			name:      "regex",
+			query:     map[string][]string{"q": {"^orders-.*"}, "regex": {"true"}},
+			wantNames: []string{"orders-created", "Orders-Legacy"},
+			wantMatches: [][]MatchRange{
+				{{Start: 0, End: 14}},
+				{{Start: 0, End: 13}},
+			},
+		},
+		{
+			name:      "regex without highlightable matches",
+			query:     map[string][]string{"q": {"^"}, "regex": {"true"}, "prefix": {"orders"}},
+			wantNames: []string{"orders", "orders-created"},
+			wantMatches: [][]MatchRange{
+				{},
+				{},
+			},
+		},
+		{
+			name:      "sort by name",
+			query:     map[string][]string{"sort": {"name"}},
+			wantNames: []string{"Orders-Legacy", "orders", "orders-created", "payments-created"},
+		},
+		{
+			name:      "sort by name descending",
+			query:     map[string][]string{"sort": {"-name"}},
+			wantNames: []string{"payments-created", "orders-created", "orders", "Orders-Legacy"},
+		},
+		{
+			name:      "sort by relevance",
+			query:     map[string][]string{"q": {"created"}, "sort": {"relevance"}},
+			wantNames: []string{"orders-created", "payments-created"},
+			wantMatches: [][]MatchRange{
+				{{Start: 7, End: 14}},
+				{{Start: 9, End: 16}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tq, err := parseTopicQuery(tt.query)
+			if err != nil {
+				t.Fatalf("could not parse query: %v", err)
+			}
+
+			got := tq.apply(topics)
+
+			names := make([]string, len(got))
+			var matches [][]MatchRange
+			for i, topic := range got {
+				names[i] = topic.Name
+				if topic.Matches != nil {
+					matches = append(matches, topic.Matches)
+				}
+			}
+
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("expected topics %v, got %v", tt.wantNames, names)
+			}
+			if !reflect.DeepEqual(matches, tt.wantMatches) {
+				t.Errorf("expected matches %v, got %v", tt.wantMatches, matches)
+			}
+		})
+	}
+}
+
+func TestParseTopicQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query map[string][]string
+	}{
+		{name: "invalid regex flag", query: map[string][]string{"regex": {"maybe"}}},
+		{name: "invalid regex", query: map[string][]string{"q": {"("}, "regex": {"true"}}},
+		{name: "invalid sort", query: map[string][]string{"sort": {"size"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTopicQuery(tt.query)
+			if err == nil {
+				t.Errorf("expected error, got none")
+			}
+		})
+	}
+}
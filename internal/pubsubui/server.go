@@ -17,10 +17,13 @@ package pubsubui
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
@@ -30,13 +33,14 @@ import (
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
 	"github.com/go-chi/chi/v5"
-	"github.com/lithammer/shortuuid/v4"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/iterator"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
@@ -45,23 +49,58 @@ const (
 )
 
 const (
-	pageDefault           = "1"
-	pageSizeStrDefault    = "10"
-	queryParamKeyPage     = "page"
-	queryParamKeyPageSize = "pageSize"
+	pageDefault             = "1"
+	pageSizeStrDefault      = "10"
+	queryParamKeyPage       = "page"
+	queryParamKeyPageSize   = "pageSize"
+	queryParamKeyMode       = "mode"
+	queryParamKeyEncoding   = "encoding"
+	queryParamKeyReconcile  = "reconcile"
+	queryParamKeyPrune      = "prune"
+	formFieldKeyFile        = "file"
+	formFieldKeyMessageType = "messageType"
+)
+
+const (
+	queryParamKeySchema         = "schema"
+	queryParamKeySchemaEncoding = "schemaEncoding"
+)
+
+const queryParamKeySubscription = "subscription"
+
+const queryParamKeyAllowDeadLetter = "allowDeadLetter"
+
+const queryParamKeyRefresh = "refresh"
+
+const headerKeyLastEventID = "Last-Event-ID"
+
+const (
+	queryParamKeySubscriptions = "subscriptions"
+	subscriptionsActionKeep    = "keep"
+	subscriptionsActionDelete  = "delete"
+	subscriptionsActionDetach  = "detach"
 )
 
 type Server struct {
 	additionalRouterConfigs []func(chi.Router)
+	instance                string
 	statusMu                sync.Mutex
+	mu                      sync.RWMutex
 	projectIDs              []string
 	projectsSet             bool
 	clients                 map[string]*pubsub.Client
 	clientsSet              bool
+	schemaClients           map[string]*pubsub.SchemaClient
+	schemaClientsSet        bool
+	subscriberClients       map[string]*vkit.SubscriberClient
+	subscriberClientsSet    bool
 	payloads                map[string][]MessagePayload
+	protobufTypes           map[string]protoreflect.MessageDescriptor
+	uploadedProtobufTypes   map[string]protoreflect.MessageDescriptor
+	topics                  Topics
 	topicsSet               bool
 	topicsCreated           bool
-	topicsCache             map[string][]Topic
+	topicsCache             *topicsCache
 	sse                     *ServerSSE
 }
 
@@ -69,20 +108,24 @@ func handleServerSetup(
 	srv *Server,
 	projectsCh <-chan []string,
 	clientsCh <-chan map[string]*pubsub.Client,
+	schemaClientsCh <-chan map[string]*pubsub.SchemaClient,
+	subscriberClientsCh <-chan map[string]*vkit.SubscriberClient,
 	topicsCh <-chan Topics,
 	topicsCreatedCh <-chan struct{},
 ) {
 	isReady := func() bool {
-		return srv.projectsSet && srv.clientsSet && srv.topicsSet && srv.topicsCreated
+		return srv.projectsSet && srv.clientsSet && srv.schemaClientsSet && srv.subscriberClientsSet && srv.topicsSet &&
+			srv.topicsCreated
 	}
 
 Setup:
 	for {
 		select {
 		case projects := <-projectsCh:
+			srv.setProjectIDs(projects)
+
 			srv.statusMu.Lock()
 
-			srv.projectIDs = projects
 			srv.projectsSet = true
 
 			ready := isReady()
@@ -95,9 +138,10 @@ Setup:
 				break Setup
 			}
 		case clients := <-clientsCh:
+			srv.setClients(clients)
+
 			srv.statusMu.Lock()
 
-			srv.clients = clients
 			srv.clientsSet = true
 
 			ready := isReady()
@@ -106,13 +150,46 @@ Setup:
 
 			logWithPrefix("server: received Google Cloud Pub/Sub clients")
 
+			if ready {
+				break Setup
+			}
+		case schemaClients := <-schemaClientsCh:
+			srv.setSchemaClients(schemaClients)
+
+			srv.statusMu.Lock()
+
+			srv.schemaClientsSet = true
+
+			ready := isReady()
+
+			srv.statusMu.Unlock()
+
+			logWithPrefix("server: received Google Cloud Pub/Sub schema clients")
+
+			if ready {
+				break Setup
+			}
+		case subscriberClients := <-subscriberClientsCh:
+			srv.setSubscriberClients(subscriberClients)
+
+			srv.statusMu.Lock()
+
+			srv.subscriberClientsSet = true
+
+			ready := isReady()
+
+			srv.statusMu.Unlock()
+
+			logWithPrefix("server: received Google Cloud Pub/Sub subscriber clients")
+
 			if ready {
 				break Setup
 			}
 		case topics := <-topicsCh:
+			srv.setTopics(topics)
+
 			srv.statusMu.Lock()
 
-			srv.payloads = topics.Payloads()
 			srv.topicsSet = true
 
 			ready := isReady()
@@ -148,25 +225,179 @@ func newServer(
 	ctx context.Context,
 	projectsCh <-chan []string,
 	clientsCh <-chan map[string]*pubsub.Client,
+	schemaClientsCh <-chan map[string]*pubsub.SchemaClient,
+	subscriberClientsCh <-chan map[string]*vkit.SubscriberClient,
 	topicsCh <-chan Topics,
 	topicsCreatedCh <-chan struct{},
+	instance string,
+	topicsCacheTTL time.Duration,
 	additionalRouterConfigs ...func(chi.Router),
 ) *Server {
 	srv := &Server{
 		additionalRouterConfigs: additionalRouterConfigs,
-		topicsCache:             make(map[string][]Topic),
+		instance:                instance,
+		topicsCache:             newTopicsCache(topicsCacheTTL),
+		uploadedProtobufTypes:   make(map[string]protoreflect.MessageDescriptor),
 	}
 
-	go handleServerSetup(srv, projectsCh, clientsCh, topicsCh, topicsCreatedCh)
+	go handleServerSetup(srv, projectsCh, clientsCh, schemaClientsCh, subscriberClientsCh, topicsCh, topicsCreatedCh)
+
+	srv.sse = newServerSSE(ctx)
+
+	return srv
+}
+
+func (srv *Server) setProjectIDs(projectIDs []string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.projectIDs = projectIDs
+}
+
+// setClients replaces the clients. The map is never modified after it has been set, so it can safely be handed out
+// by allClients.
+func (srv *Server) setClients(clients map[string]*pubsub.Client) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.clients = clients
+}
+
+// setSchemaClients replaces the schema clients. Like the clients, the map is never modified after it has been set.
+func (srv *Server) setSchemaClients(schemaClients map[string]*pubsub.SchemaClient) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.schemaClients = schemaClients
+}
+
+// setSubscriberClients replaces the subscriber clients, which are shared by all WebSocket connections. Like the
+// clients, the map is never modified after it has been set.
+func (srv *Server) setSubscriberClients(subscriberClients map[string]*vkit.SubscriberClient) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.subscriberClients = subscriberClients
+}
+
+func (srv *Server) setTopics(topics Topics) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.topics = topics
+	srv.payloads = topics.Payloads()
+	srv.protobufTypes = topics.ProtobufTypes()
+}
+
+// reload replaces the configuration of a running server, e.g. after the config file changed.
+func (srv *Server) reload(
+	projectIDs []string,
+	clients map[string]*pubsub.Client,
+	schemaClients map[string]*pubsub.SchemaClient,
+	subscriberClients map[string]*vkit.SubscriberClient,
+	topics Topics,
+) {
+	srv.setProjectIDs(projectIDs)
+	srv.setClients(clients)
+	srv.setSchemaClients(schemaClients)
+	srv.setSubscriberClients(subscriberClients)
+	srv.setTopics(topics)
+
+	// The clients may point somewhere else now, so the cached topics cannot be trusted anymore.
+	srv.topicsCache.invalidate()
+}
+
+func (srv *Server) allProjectIDs() []string {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.projectIDs
+}
+
+func (srv *Server) allClients() map[string]*pubsub.Client {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.clients
+}
+
+func (srv *Server) client(projectID string) (*pubsub.Client, bool) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	client, ok := srv.clients[projectID]
+
+	return client, ok
+}
+
+func (srv *Server) allSchemaClients() map[string]*pubsub.SchemaClient {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.schemaClients
+}
+
+func (srv *Server) schemaClient(projectID string) (*pubsub.SchemaClient, bool) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	schemaClient, ok := srv.schemaClients[projectID]
+
+	return schemaClient, ok
+}
+
+func (srv *Server) allSubscriberClients() map[string]*vkit.SubscriberClient {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.subscriberClients
+}
 
-	srv.sse = &ServerSSE{
-		subscribeCh:   make(chan SSEClient),
-		unSubscribeCh: make(chan SSEClient),
+func (srv *Server) subscriberClient(projectID string) (*vkit.SubscriberClient, bool) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	subscriberClient, ok := srv.subscriberClients[projectID]
+
+	return subscriberClient, ok
+}
+
+func (srv *Server) configuredTopics() Topics {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.topics
+}
+
+func (srv *Server) topicPayloads(projectID, topicName string) []MessagePayload {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.payloads[fmt.Sprintf("%s/%s", projectID, topicName)]
+}
+
+// setUploadedProtobufType registers the protobuf message type of a topic. Uploaded message types are kept when the
+// config file is reloaded and take precedence over the ones in the config file.
+func (srv *Server) setUploadedProtobufType(projectID, topicName string, md protoreflect.MessageDescriptor) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.uploadedProtobufTypes[fmt.Sprintf("%s/%s", projectID, topicName)] = md
+}
+
+func (srv *Server) topicProtobufType(projectID, topicName string) (protoreflect.MessageDescriptor, bool) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	key := fmt.Sprintf("%s/%s", projectID, topicName)
+
+	if md, ok := srv.uploadedProtobufTypes[key]; ok {
+		return md, true
 	}
 
-	go srv.sse.handle(ctx)
+	md, ok := srv.protobufTypes[key]
 
-	return srv
+	return md, ok
 }
 
 type listProjectsResponse struct {
@@ -174,20 +405,38 @@ type listProjectsResponse struct {
 }
 
 type createTopicRequest struct {
-	Name string `json:"name"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Schema *TopicSchema      `json:"schema,omitempty"`
 }
 
 type createTopicResponse struct {
 	Topic Topic `json:"topic"`
 }
 
+// listedTopic is a topic in a list response, together with the parts of its name matched by the search.
+type listedTopic struct {
+	Topic
+	Matches []MatchRange `json:"matches,omitempty"`
+}
+
 type listTopicsResponse struct {
-	ProjectID  string  `json:"projectId"`
-	Topics     []Topic `json:"topics"`
-	TotalItems uint    `json:"totalItems"`
-	Page       uint    `json:"page"`
-	PageSize   uint    `json:"pageSize"`
-	TotalPages uint    `json:"totalPages"`
+	ProjectID  string        `json:"projectId"`
+	Topics     []listedTopic `json:"topics"`
+	TotalItems uint          `json:"totalItems"`
+	Page       uint          `json:"page"`
+	PageSize   uint          `json:"pageSize"`
+	TotalPages uint          `json:"totalPages"`
+}
+
+type topicConfigResponse struct {
+	Topic             TopicConfig `json:"topic"`
+	SubscriptionCount int         `json:"subscriptionCount"`
+	Subscriptions     []string    `json:"subscriptions"`
+}
+
+type updateTopicResponse struct {
+	Topic TopicConfig `json:"topic"`
 }
 
 type publishMessageResponse struct {
@@ -195,17 +444,107 @@ type publishMessageResponse struct {
 	MessageID string `json:"messageId"`
 }
 
+type publishMessagesResponse struct {
+	ProjectID string          `json:"projectId"`
+	Published uint            `json:"published"`
+	Failed    uint            `json:"failed"`
+	Results   []publishResult `json:"results"`
+}
+
 type createSubscriptionRequest struct {
 	Name string `json:"name"`
+	SubscriptionSettings
+}
+
+type listSubscriptionsResponse struct {
+	ProjectID     string         `json:"projectId"`
+	Subscriptions []Subscription `json:"subscriptions"`
+	TotalItems    uint           `json:"totalItems"`
+	Page          uint           `json:"page"`
+	PageSize      uint           `json:"pageSize"`
+	TotalPages    uint           `json:"totalPages"`
+}
+
+type listTopicSubscriptionsResponse struct {
+	ProjectID     string         `json:"projectId"`
+	TopicID       string         `json:"topicId"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+type deadLetterResponse struct {
+	ProjectID           string            `json:"projectId"`
+	SubscriptionID      string            `json:"subscriptionId"`
+	DeadLetterPolicy    *DeadLetterPolicy `json:"deadLetterPolicy"`
+	DeadLetterProjectID string            `json:"deadLetterProjectId"`
+	DeadLetterTopicID   string            `json:"deadLetterTopicId"`
+	Subscriptions       []Subscription    `json:"subscriptions"`
+}
+
+type redriveResponse struct {
+	Redriven int             `json:"redriven"`
+	Failed   int             `json:"failed"`
+	Results  []redriveResult `json:"results"`
+}
+
+type protobufTypeResponse struct {
+	ProjectID   string `json:"projectId"`
+	TopicID     string `json:"topicId"`
+	MessageType string `json:"messageType"`
+}
+
+type retentionResponse struct {
+	ProjectID      string    `json:"projectId"`
+	SubscriptionID string    `json:"subscriptionId"`
+	Retention      Retention `json:"retention"`
+}
+
+type listSnapshotsResponse struct {
+	ProjectID  string     `json:"projectId"`
+	Snapshots  []Snapshot `json:"snapshots"`
+	TotalItems uint       `json:"totalItems"`
+	Page       uint       `json:"page"`
+	PageSize   uint       `json:"pageSize"`
+	TotalPages uint       `json:"totalPages"`
+}
+
+type snapshotResponse struct {
+	Snapshot Snapshot `json:"snapshot"`
+}
+
+type listSchemasResponse struct {
+	ProjectID  string   `json:"projectId"`
+	Schemas    []Schema `json:"schemas"`
+	TotalItems uint     `json:"totalItems"`
+	Page       uint     `json:"page"`
+	PageSize   uint     `json:"pageSize"`
+	TotalPages uint     `json:"totalPages"`
+}
+
+type createSchemaRequest struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Definition string `json:"definition"`
+}
+
+type schemaResponse struct {
+	Schema Schema `json:"schema"`
+}
+
+type validateMessageResponse struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+type getSubscriptionResponse struct {
+	Subscription Subscription `json:"subscription"`
 }
 
 type createSubscriptionResponse struct {
-	Subscription struct {
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		ProjectID string `json:"projectId"`
-		TopicID   string `json:"topicId"`
-	} `json:"subscription"`
+	Subscription Subscription `json:"subscription"`
+}
+
+type updateSubscriptionResponse struct {
+	Subscription Subscription `json:"subscription"`
 }
 
 func getQueryParamOrDefault(qry url.Values, key, def string) string {
@@ -216,6 +555,38 @@ func getQueryParamOrDefault(qry url.Values, key, def string) string {
 	return qry.Get(key)
 }
 
+func parsePagination(qry url.Values) (uint, uint, error) {
+	pageStr := getQueryParamOrDefault(qry, queryParamKeyPage, pageDefault)
+	page, err := strconv.ParseUint(pageStr, 10, strconv.IntSize)
+	if err != nil || page == 0 {
+		return 0, 0, errors.Errorf("invalid page %q", pageStr)
+	}
+
+	pageSizeStr := getQueryParamOrDefault(qry, queryParamKeyPageSize, pageSizeStrDefault)
+	pageSize, err := strconv.ParseUint(pageSizeStr, 10, strconv.IntSize)
+	if err != nil || pageSize == 0 {
+		return 0, 0, errors.Errorf("invalid page size %q", pageSizeStr)
+	}
+
+	return uint(page), uint(pageSize), nil
+}
+
+// paginate returns the requested page of items together with the total amount of items and pages.
+func paginate[T any](items []T, page, pageSize uint) ([]T, uint, uint) {
+	totalItems := uint(len(items))
+	totalPages := uint(math.Ceil(float64(totalItems) / float64(pageSize)))
+	offset := (page - 1) * pageSize
+	if offset > totalItems {
+		offset = totalItems
+	}
+	limit := offset + pageSize
+	if limit > totalItems {
+		limit = totalItems
+	}
+
+	return items[offset:limit], totalItems, totalPages
+}
+
 func topicNameFromTopicID(topicID string) string {
 	split := strings.Split(topicID, "/")
 	return split[len(split)-1]
@@ -313,7 +684,7 @@ func handleGoogleError(w http.ResponseWriter, actionTried string, err error) {
 
 func (srv *Server) ListProjects(w http.ResponseWriter, r *http.Request) {
 	bts, err := json.Marshal(listProjectsResponse{
-		Projects: srv.projectIDs,
+		Projects: srv.allProjectIDs(),
 	})
 	if err != nil {
 		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode projects as JSON"))
@@ -324,12 +695,60 @@ func (srv *Server) ListProjects(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, "projects.json", time.Time{}, bytes.NewReader(bts))
 }
 
+func (srv *Server) Plan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	qry := r.URL.Query()
+
+	reconcileStr := getQueryParamOrDefault(qry, queryParamKeyReconcile, "false")
+	reconcile, err := strconv.ParseBool(reconcileStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid reconcile %q", reconcileStr), http.StatusBadRequest)
+		return
+	}
+
+	pruneStr := getQueryParamOrDefault(qry, queryParamKeyPrune, "false")
+	prune, err := strconv.ParseBool(pruneStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid prune %q", pruneStr), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := planTopics(
+		ctx,
+		srv.allClients(),
+		srv.allSchemaClients(),
+		srv.configuredTopics(),
+		reconcile,
+		prune,
+	)
+	if err != nil {
+		// Errors which do not come from Pub/Sub are caused by the config, like a project without a client.
+		if _, ok := status.FromError(errors.Cause(err)); ok {
+			handleGoogleError(w, "plan topics", errors.Cause(err))
+			return
+		}
+
+		http.Error(w, fmt.Sprintf("could not plan topics: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	bts, err := json.Marshal(plan)
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode plan as JSON"))
+		http.Error(w, "could not encode plan as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "plan.json", time.Time{}, bytes.NewReader(bts))
+}
+
 func (srv *Server) CreateTopic(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	projectID := chi.URLParam(r, "projectID")
 
-	client, ok := srv.clients[projectID]
+	client, ok := srv.client(projectID)
 	if !ok {
 		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
 		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
@@ -343,7 +762,24 @@ func (srv *Server) CreateTopic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	topic, err := client.CreateTopic(ctx, req.Name)
+	err = validateLabels(req.Labels)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	topicCfg := pubsub.TopicConfig{
+		Labels: req.Labels,
+	}
+	if req.Schema != nil {
+		topicCfg.SchemaSettings, err = req.Schema.schemaSettings(projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	topic, err := client.CreateTopicWithConfig(ctx, req.Name, &topicCfg)
 	if err != nil {
 		handleGoogleError(w, "create topic", err)
 		return
@@ -351,17 +787,18 @@ func (srv *Server) CreateTopic(w http.ResponseWriter, r *http.Request) {
 
 	topicID := topic.ID()
 	topicName := topicNameFromTopicID(topicID)
-	topicKey := fmt.Sprintf("%s/%s", projectID, topicName)
-	payloads := srv.payloads[topicKey]
 
 	newTopic := Topic{
 		ID:        topicID,
 		Name:      topicName,
 		ProjectID: projectID,
-		Payloads:  payloads,
+		Labels:    topicCfg.Labels,
+		Schema:    topicSchemaFromSettings(topicCfg.SchemaSettings),
 	}
 
-	srv.topicsCache[projectID] = append(srv.topicsCache[projectID], newTopic)
+	srv.topicsCache.put(newTopic)
+
+	newTopic.Payloads = srv.topicPayloads(projectID, topicName)
 
 	bts, err := json.Marshal(createTopicResponse{newTopic})
 	if err != nil {
@@ -378,34 +815,39 @@ func (srv *Server) ListTopics(w http.ResponseWriter, r *http.Request) {
 
 	projectID := chi.URLParam(r, "projectID")
 
-	qry := r.URL.Query()
+	page, pageSize, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	pageStr := getQueryParamOrDefault(qry, queryParamKeyPage, pageDefault)
-	page, err := strconv.ParseUint(pageStr, 10, strconv.IntSize)
+	query, err := parseTopicQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("invalid page %q", pageStr), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	pageSizeStr := getQueryParamOrDefault(qry, queryParamKeyPageSize, pageSizeStrDefault)
-	pageSize, err := strconv.ParseUint(pageSizeStr, 10, strconv.IntSize)
+	refreshStr := getQueryParamOrDefault(r.URL.Query(), queryParamKeyRefresh, "false")
+	refresh, err := strconv.ParseBool(refreshStr)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("invalid page size %q", pageSizeStr), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("invalid refresh flag %q", refreshStr), http.StatusBadRequest)
 		return
 	}
 
-	client, ok := srv.clients[projectID]
+	client, ok := srv.client(projectID)
 	if !ok {
 		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
 		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
 		return
 	}
 
-	topics, ok := srv.topicsCache[projectID]
-	if !ok {
+	topics, generation, ok := srv.topicsCache.get(projectID)
+	if !ok || refresh {
+		topics = make([]Topic, 0)
+
 		topicIt := client.Topics(ctx)
 		for {
-			topic, err := topicIt.Next()
+			cfg, err := topicIt.NextConfig()
 			if errors.Is(err, iterator.Done) {
 				break
 			}
@@ -414,36 +856,28 @@ func (srv *Server) ListTopics(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			topicID := topic.ID()
-			topicName := topicNameFromTopicID(topicID)
-			topicKey := fmt.Sprintf("%s/%s", projectID, topicName)
-			payloads := srv.payloads[topicKey]
-
-			topics = append(topics, Topic{
-				ID:        topicID,
-				Name:      topicName,
-				ProjectID: projectID,
-				Payloads:  payloads,
-			})
+			topics = append(topics, topicFromConfig(projectID, *cfg))
 		}
 
-		srv.topicsCache[projectID] = topics
+		srv.topicsCache.set(projectID, generation, topics)
 	}
 
-	totalItems := uint(len(topics))
-	totalPages := uint(math.Ceil(float64(totalItems) / float64(pageSize)))
-	offset := uint((page - 1) * pageSize)
-	limit := offset + uint(pageSize)
-	if limit > totalItems {
-		limit = totalItems
+	// Filtering and sorting happen before paginating, so the totals only count the selected topics.
+	cachedPageTopics, totalItems, totalPages := paginate(query.apply(topics), page, pageSize)
+
+	// Payloads are added when responding rather than when caching, so changes to the config file are picked up.
+	pageTopics := make([]listedTopic, len(cachedPageTopics))
+	for i, topic := range cachedPageTopics {
+		topic.Payloads = srv.topicPayloads(projectID, topic.Name)
+		pageTopics[i] = topic
 	}
 
 	bts, err := json.Marshal(listTopicsResponse{
 		ProjectID:  projectID,
-		Topics:     topics[offset:limit],
+		Topics:     pageTopics,
 		TotalItems: totalItems,
-		Page:       uint(page),
-		PageSize:   uint(pageSize),
+		Page:       page,
+		PageSize:   pageSize,
 		TotalPages: totalPages,
 	})
 	if err != nil {
@@ -455,122 +889,408 @@ func (srv *Server) ListTopics(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, "topics.json", time.Time{}, bytes.NewReader(bts))
 }
 
-func (srv *Server) Publish(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) DeleteTopic(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	projectID := chi.URLParam(r, "projectID")
 	topicID := chi.URLParam(r, "topicID")
 
-	client, ok := srv.clients[projectID]
-	if !ok {
-		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
-		http.Error(w, "project %q not supported", http.StatusBadRequest)
+	subscriptionsAction := getQueryParamOrDefault(r.URL.Query(), queryParamKeySubscriptions, subscriptionsActionKeep)
+	switch subscriptionsAction {
+	case subscriptionsActionKeep, subscriptionsActionDelete, subscriptionsActionDetach:
+	default:
+		http.Error(w, fmt.Sprintf("invalid subscriptions action %q", subscriptionsAction), http.StatusBadRequest)
 		return
 	}
 
-	msg, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		logWithPrefix("server: %+v", errors.Wrap(err, "could not read message body"))
-		http.Error(w, "could not read message body", http.StatusInternalServerError)
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
 		return
 	}
 
 	topic := client.Topic(topicID)
-	res := topic.Publish(ctx, &pubsub.Message{
-		Data: msg,
-	})
 
-	id, err := res.Get(ctx)
-	if err != nil {
-		handleGoogleError(w, "publish message", err)
-		return
+	if subscriptionsAction != subscriptionsActionKeep {
+		subIt := topic.Subscriptions(ctx)
+		for {
+			sub, err := subIt.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				handleGoogleError(w, "list topic subscriptions", err)
+				return
+			}
+
+			if subscriptionsAction == subscriptionsActionDelete {
+				err = sub.Delete(ctx)
+			} else {
+				_, err = client.DetachSubscription(ctx, sub.String())
+			}
+			if err != nil {
+				actionTried := fmt.Sprintf("%s subscription %q of topic %q", subscriptionsAction, sub.ID(), topicID)
+				handleGoogleError(w, actionTried, err)
+				return
+			}
+
+			logWithPrefix(
+				"server: subscription %q of topic %q in project %q: %s succeeded",
+				sub.ID(),
+				topicID,
+				projectID,
+				subscriptionsAction,
+			)
+		}
 	}
 
-	bts, err := json.Marshal(publishMessageResponse{
-		ProjectID: projectID,
-		MessageID: id,
-	})
+	err := topic.Delete(ctx)
 	if err != nil {
-		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode publish result as JSON"))
-		http.Error(w, "could not encode publish result as JSON", http.StatusInternalServerError)
+		handleGoogleError(w, fmt.Sprintf("delete topic %q in project %q", topicID, projectID), err)
 		return
 	}
 
-	http.ServeContent(w, r, "publish_result.json", time.Time{}, bytes.NewReader(bts))
-}
+	srv.topicsCache.remove(projectID, topicID)
 
-func (srv *Server) CreateSubscription(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	logWithPrefix("server: deleted topic %q in project %q", topicID, projectID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
 
+func (srv *Server) InvalidateTopicsCache(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "projectID")
-	topicID := chi.URLParam(r, "topicID")
 
-	client, ok := srv.clients[projectID]
+	_, ok := srv.client(projectID)
 	if !ok {
 		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
 		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
 		return
 	}
 
-	var req createSubscriptionRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "could not decode create topic request", http.StatusBadRequest)
-		return
-	}
+	srv.topicsCache.invalidate(projectID)
 
-	topic := client.Topic(topicID)
-	exists, err := topic.Exists(ctx)
-	if err != nil {
-		handleGoogleError(w, "check for topic existence", err)
+	logWithPrefix("server: invalidated cached topics of project %q", projectID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) InvalidateAllTopicsCaches(w http.ResponseWriter, r *http.Request) {
+	srv.topicsCache.invalidate()
+
+	logWithPrefix("server: invalidated cached topics of all projects")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) GetTopicConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
 		return
 	}
-	if !exists {
-		http.Error(w, fmt.Sprintf("topic %q does not exist", topicID), http.StatusBadRequest)
+
+	topic := client.Topic(topicID)
+
+	cfg, err := topic.Config(ctx)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("get config of topic %q in project %q", topicID, projectID), err)
 		return
 	}
 
-	subscription, err := client.CreateSubscription(ctx, req.Name, pubsub.SubscriptionConfig{
-		Topic: topic,
+	subscriptions := make([]string, 0)
+
+	subIt := topic.Subscriptions(ctx)
+	for {
+		sub, err := subIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			handleGoogleError(w, "list topic subscriptions", err)
+			return
+		}
+
+		subscriptions = append(subscriptions, sub.ID())
+	}
+
+	bts, err := json.Marshal(topicConfigResponse{
+		Topic:             topicConfigFromConfig(projectID, cfg),
+		SubscriptionCount: len(subscriptions),
+		Subscriptions:     subscriptions,
 	})
 	if err != nil {
-		actionTried := fmt.Sprintf("create subscription %q on topic %q in project %q", req.Name, topicID, projectID)
-		handleGoogleError(w, actionTried, err)
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode topic config as JSON"))
+		http.Error(w, "could not encode topic config as JSON", http.StatusInternalServerError)
 		return
 	}
 
-	bts, err := json.Marshal(createSubscriptionResponse{
-		Subscription: struct {
-			ID        string `json:"id"`
-			Name      string `json:"name"`
-			ProjectID string `json:"projectId"`
-			TopicID   string `json:"topicId"`
-		}{
-			ID:        subscription.ID(),
-			Name:      req.Name,
-			ProjectID: projectID,
-			TopicID:   topicID,
-		},
+	http.ServeContent(w, r, "topic.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) UpdateTopic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req updateTopicRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode update topic request", http.StatusBadRequest)
+		return
+	}
+
+	err = req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	topic := client.Topic(topicID)
+
+	deployed, err := topic.Config(ctx)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("get config of topic %q in project %q", topicID, projectID), err)
+		return
+	}
+
+	cfg, err := topic.Update(ctx, req.topicConfigToUpdate(deployed))
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("update topic %q in project %q", topicID, projectID), err)
+		return
+	}
+
+	logWithPrefix("server: updated topic %q in project %q", topicID, projectID)
+
+	srv.topicsCache.put(topicFromConfig(projectID, cfg))
+
+	bts, err := json.Marshal(updateTopicResponse{
+		Topic: topicConfigFromConfig(projectID, cfg),
 	})
 	if err != nil {
-		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode create topic response as JSON"))
-		http.Error(w, "could not encode create topic response as JSON", http.StatusInternalServerError)
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode topic config as JSON"))
+		http.Error(w, "could not encode topic config as JSON", http.StatusInternalServerError)
 		return
 	}
 
 	http.ServeContent(w, r, "topic.json", time.Time{}, bytes.NewReader(bts))
 }
 
-func (srv *Server) Subscribe(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) Publish(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	projectID := chi.URLParam(r, "projectID")
 	topicID := chi.URLParam(r, "topicID")
 
-	client, ok := srv.clients[projectID]
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	msg, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not read message body"))
+		http.Error(w, "could not read message body", http.StatusInternalServerError)
+		return
+	}
+
+	switch encoding := getQueryParamOrDefault(r.URL.Query(), queryParamKeyEncoding, encodingText); encoding {
+	case encodingText:
+	case encodingJSON:
+		// Text is always published as is, only JSON is encoded for topics with a protobuf message type.
+		md, _ := srv.topicProtobufType(projectID, topicNameFromTopicID(topicID))
+		msg, err = encodeJSONData(md, msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case encodingBase64:
+		msg, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(msg)))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not decode base64 message body: %s", err), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("invalid encoding %q", encoding), http.StatusBadRequest)
+		return
+	}
+
+	topic := client.Topic(topicID)
+	defer topic.Stop()
+
+	id, err := publishMessage(ctx, topic, &pubsub.Message{
+		Data: msg,
+	})
+	if err != nil {
+		handleGoogleError(w, "publish message", err)
+		return
+	}
+
+	bts, err := json.Marshal(publishMessageResponse{
+		ProjectID: projectID,
+		MessageID: id,
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode publish result as JSON"))
+		http.Error(w, "could not encode publish result as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "publish_result.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) PublishStructured(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req publishMessageRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode publish message request", http.StatusBadRequest)
+		return
+	}
+
+	md, _ := srv.topicProtobufType(projectID, topicNameFromTopicID(topicID))
+
+	msg, err := req.message(md)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid publish message request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	topic := client.Topic(topicID)
+	topic.EnableMessageOrdering = msg.OrderingKey != ""
+	defer topic.Stop()
+
+	id, err := publishMessage(ctx, topic, msg)
+	if err != nil {
+		handleGoogleError(w, "publish message", err)
+		return
+	}
+
+	bts, err := json.Marshal(publishMessageResponse{
+		ProjectID: projectID,
+		MessageID: id,
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode publish result as JSON"))
+		http.Error(w, "could not encode publish result as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "publish_result.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) PublishBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile(formFieldKeyFile)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not read uploaded file %q", formFieldKeyFile), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		body = file
+	}
+
+	reqs, err := decodePublishMessageRequests(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid publish messages request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	topic := client.Topic(topicID)
+	defer topic.Stop()
+
+	md, _ := srv.topicProtobufType(projectID, topicNameFromTopicID(topicID))
+
+	results := publishMessages(ctx, topic, md, reqs)
+
+	res := publishMessagesResponse{
+		ProjectID: projectID,
+		Results:   results,
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			res.Failed++
+		} else {
+			res.Published++
+		}
+	}
+
+	logWithPrefix(
+		"server: published %d of %d messages to topic %q in project %q",
+		res.Published,
+		len(results),
+		topicID,
+		projectID,
+	)
+
+	bts, err := json.Marshal(res)
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode publish results as JSON"))
+		http.Error(w, "could not encode publish results as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "publish_results.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
 	if !ok {
 		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
-		http.Error(w, "project %q not supported", http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req createSubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode create topic request", http.StatusBadRequest)
 		return
 	}
 
@@ -585,31 +1305,1361 @@ func (srv *Server) Subscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	topicName := topicNameFromTopicID(topicID)
-	subName := fmt.Sprintf("%s_pubsubui_%s", topicName, shortuuid.New())
+	subscription, err := client.CreateSubscription(ctx, req.Name, req.subscriptionConfig(client, topic))
+	if err != nil {
+		actionTried := fmt.Sprintf("create subscription %q on topic %q in project %q", req.Name, topicID, projectID)
+		handleGoogleError(w, actionTried, err)
+		return
+	}
+
+	// Fetch the config of the subscription as created, so defaults are included in the response.
+	cfg, err := subscription.Config(ctx)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("get config of subscription %q", req.Name), err)
+		return
+	}
 
-	sub, err := client.CreateSubscription(ctx, subName, pubsub.SubscriptionConfig{
-		Topic: topic,
+	bts, err := json.Marshal(createSubscriptionResponse{
+		Subscription: subscriptionFromConfig(projectID, cfg),
 	})
 	if err != nil {
-		handleGoogleError(w, "create subscription", err)
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode create topic response as JSON"))
+		http.Error(w, "could not encode create topic response as JSON", http.StatusInternalServerError)
 		return
 	}
-	defer sub.Delete(context.Background())
 
-	messageCh := make(chan *pubsub.Message)
+	http.ServeContent(w, r, "topic.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
 
-	go srv.sse.Subscribe(w, r, messageCh)
+	page, pageSize, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	subscriptions := make([]Subscription, 0)
+
+	subIt := client.Subscriptions(ctx)
+	for {
+		cfg, err := subIt.NextConfig()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			handleGoogleError(w, "list subscriptions", err)
+			return
+		}
+
+		subscriptions = append(subscriptions, subscriptionFromConfig(projectID, *cfg))
+	}
 
-	err = sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
-		messageCh <- msg
+	pageSubscriptions, totalItems, totalPages := paginate(subscriptions, page, pageSize)
+
+	bts, err := json.Marshal(listSubscriptionsResponse{
+		ProjectID:     projectID,
+		Subscriptions: pageSubscriptions,
+		TotalItems:    totalItems,
+		Page:          page,
+		PageSize:      pageSize,
+		TotalPages:    totalPages,
 	})
 	if err != nil {
-		handleGoogleError(w, "receive messages", err)
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode subscriptions as JSON"))
+		http.Error(w, "could not encode subscriptions as JSON", http.StatusInternalServerError)
 		return
 	}
 
-	close(messageCh)
+	http.ServeContent(w, r, "subscriptions.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) ListTopicSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	subscriptions := make([]Subscription, 0)
+
+	subIt := client.Topic(topicID).Subscriptions(ctx)
+	for {
+		sub, err := subIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			handleGoogleError(w, "list topic subscriptions", err)
+			return
+		}
+
+		cfg, err := sub.Config(ctx)
+		if err != nil {
+			handleGoogleError(w, "get subscription config", err)
+			return
+		}
+
+		subscriptions = append(subscriptions, subscriptionFromConfig(projectID, cfg))
+	}
+
+	bts, err := json.Marshal(listTopicSubscriptionsResponse{
+		ProjectID:     projectID,
+		TopicID:       topicID,
+		Subscriptions: subscriptions,
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode subscriptions as JSON"))
+		http.Error(w, "could not encode subscriptions as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "subscriptions.json", time.Time{}, bytes.NewReader(bts))
+}
+
+// topicSubscriptionConfig fetches the config of the given subscription and makes sure it is attached to the given
+// topic. It writes an error response and returns false when either is not the case.
+func topicSubscriptionConfig(
+	ctx context.Context,
+	w http.ResponseWriter,
+	client *pubsub.Client,
+	topicID string,
+	subscriptionID string,
+) (*pubsub.Subscription, pubsub.SubscriptionConfig, bool) {
+	sub := client.Subscription(subscriptionID)
+
+	cfg, err := sub.Config(ctx)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("get config of subscription %q", subscriptionID), err)
+		return nil, pubsub.SubscriptionConfig{}, false
+	}
+
+	if cfg.Topic == nil || topicNameFromTopicID(cfg.Topic.String()) != topicID {
+		http.Error(
+			w,
+			fmt.Sprintf("subscription %q does not belong to topic %q", subscriptionID, topicID),
+			http.StatusNotFound,
+		)
+		return nil, pubsub.SubscriptionConfig{}, false
+	}
+
+	return sub, cfg, true
+}
+
+func (srv *Server) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	_, cfg, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	bts, err := json.Marshal(getSubscriptionResponse{
+		Subscription: subscriptionFromConfig(projectID, cfg),
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode subscription as JSON"))
+		http.Error(w, "could not encode subscription as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "subscription.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req updateSubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode update subscription request", http.StatusBadRequest)
+		return
+	}
+
+	err = req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, deployed, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	cfg, err := sub.Update(ctx, req.subscriptionConfigToUpdate(client, deployed))
+	if err != nil {
+		actionTried := fmt.Sprintf("update subscription %q on topic %q in project %q", subscriptionID, topicID, projectID)
+		handleGoogleError(w, actionTried, err)
+		return
+	}
+
+	logWithPrefix("server: updated subscription %q on topic %q in project %q", subscriptionID, topicID, projectID)
+
+	bts, err := json.Marshal(updateSubscriptionResponse{
+		Subscription: subscriptionFromConfig(projectID, cfg),
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode subscription as JSON"))
+		http.Error(w, "could not encode subscription as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "subscription.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	sub, _, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	err := sub.Delete(ctx)
+	if err != nil {
+		actionTried := fmt.Sprintf("delete subscription %q on topic %q in project %q", subscriptionID, topicID, projectID)
+		handleGoogleError(w, actionTried, err)
+		return
+	}
+
+	logWithPrefix("server: deleted subscription %q on topic %q in project %q", subscriptionID, topicID, projectID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) Subscribe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	topic := client.Topic(topicID)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		handleGoogleError(w, "check for topic existence", err)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("topic %q does not exist", topicID), http.StatusBadRequest)
+		return
+	}
+
+	decoder, err := srv.streamDecoder(ctx, r.URL.Query(), projectID, topic)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	topicName := topicNameFromTopicID(topicID)
+
+	// Clients only share a stream when its messages are decoded the same way.
+	streamKey := fmt.Sprintf(
+		"%s/%s?%s=%s&%s=%s",
+		projectID,
+		topicName,
+		queryParamKeySchema,
+		getQueryParamOrDefault(r.URL.Query(), queryParamKeySchema, ""),
+		queryParamKeySchemaEncoding,
+		getQueryParamOrDefault(r.URL.Query(), queryParamKeySchemaEncoding, ""),
+	)
+
+	lastEventID := r.Header.Get(headerKeyLastEventID)
+
+	create := func(ctx context.Context) (*pubsub.Subscription, error) {
+		subName := ephemeralSubscriptionName(topicName)
+
+		srv.sse.activate(subName)
+
+		sub, err := client.CreateSubscription(ctx, subName, ephemeralSubscriptionConfig(topic, srv.instance))
+		if err != nil {
+			srv.sse.deactivate(subName)
+			return nil, err
+		}
+
+		return sub, nil
+	}
+
+	sseClient, replay, leave, err := srv.sse.join(ctx, streamKey, lastEventID, decoder, create)
+	if err != nil {
+		handleGoogleError(w, "create subscription", err)
+		return
+	}
+	defer leave()
+
+	srv.sse.Serve(w, r, sseClient, replay)
+}
+
+func (srv *Server) SubscribeExisting(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	mode := StreamMode(getQueryParamOrDefault(r.URL.Query(), queryParamKeyMode, string(StreamModePeek)))
+	if mode != StreamModePeek && mode != StreamModeDrain {
+		http.Error(w, fmt.Sprintf("invalid mode %q", mode), http.StatusBadRequest)
+		return
+	}
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	sub, cfg, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	if mode == StreamModePeek && !peekAllowed(w, r, subscriptionID, cfg) {
+		return
+	}
+
+	decoder, err := srv.streamDecoder(ctx, r.URL.Query(), projectID, client.Topic(topicID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logWithPrefix("server: streaming subscription %q in project %q in %s mode", subscriptionID, projectID, mode)
+
+	srv.stream(w, r, sub, mode, decoder)
+}
+
+// serveWebSocket upgrades the connection to a WebSocket and lets the client pull messages from the subscription,
+// acking, nacking and extending them itself.
+func (srv *Server) serveWebSocket(
+	w http.ResponseWriter,
+	r *http.Request,
+	projectID string,
+	sub *pubsub.Subscription,
+	decoder messageDecoder,
+) {
+	ctx := r.Context()
+
+	subscriber, ok := srv.subscriberClient(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no subscriber client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded with an error.
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not upgrade to WebSocket"))
+		return
+	}
+
+	newWSSession(conn, subscriber, sub.String(), decoder).run(ctx)
+}
+
+// SubscribeWebSocket streams the messages of a topic over a WebSocket, using a subscription of its own.
+func (srv *Server) SubscribeWebSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	topic := client.Topic(topicID)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		handleGoogleError(w, "check for topic existence", err)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("topic %q does not exist", topicID), http.StatusBadRequest)
+		return
+	}
+
+	decoder, err := srv.streamDecoder(ctx, r.URL.Query(), projectID, topic)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subName := ephemeralSubscriptionName(topicNameFromTopicID(topicID))
+
+	srv.sse.activate(subName)
+	defer srv.sse.deactivate(subName)
+
+	sub, err := client.CreateSubscription(ctx, subName, ephemeralSubscriptionConfig(topic, srv.instance))
+	if err != nil {
+		handleGoogleError(w, "create subscription", err)
+		return
+	}
+	defer func() {
+		err := sub.Delete(context.Background())
+		if err != nil {
+			logWithPrefix("server: could not delete subscription %q: %+v", subName, err)
+		}
+	}()
+
+	srv.serveWebSocket(w, r, projectID, sub, decoder)
+}
+
+// SubscribeExistingWebSocket streams the messages of an existing subscription over a WebSocket.
+func (srv *Server) SubscribeExistingWebSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	sub, _, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	decoder, err := srv.streamDecoder(ctx, r.URL.Query(), projectID, client.Topic(topicID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logWithPrefix("server: streaming subscription %q in project %q over WebSocket", subscriptionID, projectID)
+
+	srv.serveWebSocket(w, r, projectID, sub, decoder)
+}
+
+// peekAllowed tells whether the subscription may be peeked at. Every nack of a peeked message counts as a delivery
+// attempt, so messages of a subscription with a dead letter policy may get dead lettered by peeking. Such subscriptions
+// are only peeked at when the client explicitly allows it, otherwise an error response is written.
+func peekAllowed(w http.ResponseWriter, r *http.Request, subscriptionID string, cfg pubsub.SubscriptionConfig) bool {
+	if cfg.DeadLetterPolicy == nil {
+		return true
+	}
+
+	allowStr := getQueryParamOrDefault(r.URL.Query(), queryParamKeyAllowDeadLetter, "false")
+	allow, err := strconv.ParseBool(allowStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid %s flag %q", queryParamKeyAllowDeadLetter, allowStr), http.StatusBadRequest)
+		return false
+	}
+	if !allow {
+		http.Error(
+			w,
+			fmt.Sprintf(
+				"subscription %q has a dead letter policy and peeking counts as a delivery attempt, set %s=true to "+
+					"peek anyway",
+				subscriptionID,
+				queryParamKeyAllowDeadLetter,
+			),
+			http.StatusConflict,
+		)
+		return false
+	}
+
+	return true
+}
+
+// stream receives messages from the given subscription and streams them to the client until it disconnects.
+func (srv *Server) stream(
+	w http.ResponseWriter,
+	r *http.Request,
+	sub *pubsub.Subscription,
+	mode StreamMode,
+	decoder messageDecoder,
+) {
+	ctx := r.Context()
+
+	// Peeked messages are nacked right after streaming them, their lease is kept short in case the client is slow.
+	if mode == StreamModePeek {
+		sub.ReceiveSettings.MaxExtension = peekMaxExtension
+		sub.ReceiveSettings.MinExtensionPeriod = peekMaxExtensionPeriod
+		sub.ReceiveSettings.MaxExtensionPeriod = peekMaxExtensionPeriod
+	}
+
+	messageCh := make(chan *pubsub.Message)
+
+	go srv.sse.Subscribe(w, r, messageCh, mode, decoder)
+
+	err := sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		select {
+		case messageCh <- msg:
+		case <-ctx.Done():
+			msg.Nack()
+		}
+	})
+	if err != nil {
+		handleGoogleError(w, "receive messages", err)
+		return
+	}
+
+	close(messageCh)
+}
+
+// deadLetterTopic resolves the dead letter topic of the given subscription, together with the client of the project
+// it lives in. It writes an error response and returns false when the subscription has no dead letter policy or the
+// project of the dead letter topic is not configured.
+func (srv *Server) deadLetterTopic(
+	ctx context.Context,
+	w http.ResponseWriter,
+	client *pubsub.Client,
+	topicID string,
+	subscriptionID string,
+) (*pubsub.Client, *pubsub.Topic, pubsub.SubscriptionConfig, bool) {
+	_, cfg, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return nil, nil, pubsub.SubscriptionConfig{}, false
+	}
+
+	if cfg.DeadLetterPolicy == nil {
+		http.Error(
+			w,
+			fmt.Sprintf("subscription %q has no dead letter policy", subscriptionID),
+			http.StatusBadRequest,
+		)
+		return nil, nil, pubsub.SubscriptionConfig{}, false
+	}
+
+	dlqProjectID, dlqTopicID, err := deadLetterTopicPath(cfg.DeadLetterPolicy.DeadLetterTopic)
+	if err != nil {
+		logWithPrefix("server: %+v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, pubsub.SubscriptionConfig{}, false
+	}
+
+	dlqClient, ok := srv.client(dlqProjectID)
+	if !ok {
+		http.Error(
+			w,
+			fmt.Sprintf("project %q of dead letter topic %q not supported", dlqProjectID, dlqTopicID),
+			http.StatusBadRequest,
+		)
+		return nil, nil, pubsub.SubscriptionConfig{}, false
+	}
+
+	return dlqClient, dlqClient.Topic(dlqTopicID), cfg, true
+}
+
+// deadLetterSubscription finds the subscription on the dead letter topic to read dead lettered messages from. Without
+// a subscription ID the dead letter topic must have exactly one subscription. It writes an error response and returns
+// false when no subscription can be found.
+func deadLetterSubscription(
+	ctx context.Context,
+	w http.ResponseWriter,
+	dlqClient *pubsub.Client,
+	dlqTopic *pubsub.Topic,
+	subscriptionID string,
+) (*pubsub.Subscription, bool) {
+	dlqTopicID := topicNameFromTopicID(dlqTopic.String())
+
+	if subscriptionID != "" {
+		sub, _, ok := topicSubscriptionConfig(ctx, w, dlqClient, dlqTopicID, subscriptionID)
+		return sub, ok
+	}
+
+	subs := make([]*pubsub.Subscription, 0)
+
+	subIt := dlqTopic.Subscriptions(ctx)
+	for {
+		sub, err := subIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			handleGoogleError(w, fmt.Sprintf("list subscriptions of dead letter topic %q", dlqTopicID), err)
+			return nil, false
+		}
+
+		subs = append(subs, sub)
+	}
+
+	if len(subs) != 1 {
+		http.Error(
+			w,
+			fmt.Sprintf(
+				"dead letter topic %q has %d subscriptions, select one using %q",
+				dlqTopicID,
+				len(subs),
+				queryParamKeySubscription,
+			),
+			http.StatusBadRequest,
+		)
+		return nil, false
+	}
+
+	return subs[0], true
+}
+
+// GetDeadLetter shows the dead letter policy of a subscription and the subscriptions on its dead letter topic.
+func (srv *Server) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	_, dlqTopic, cfg, ok := srv.deadLetterTopic(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	dlqProjectID, dlqTopicID, _ := deadLetterTopicPath(dlqTopic.String())
+
+	subscriptions := make([]Subscription, 0)
+
+	subIt := dlqTopic.Subscriptions(ctx)
+	for {
+		sub, err := subIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			handleGoogleError(w, fmt.Sprintf("list subscriptions of dead letter topic %q", dlqTopicID), err)
+			return
+		}
+
+		subCfg, err := sub.Config(ctx)
+		if err != nil {
+			handleGoogleError(w, "get subscription config", err)
+			return
+		}
+
+		subscriptions = append(subscriptions, subscriptionFromConfig(dlqProjectID, subCfg))
+	}
+
+	bts, err := json.Marshal(deadLetterResponse{
+		ProjectID:           projectID,
+		SubscriptionID:      subscriptionID,
+		DeadLetterPolicy:    subscriptionFromConfig(projectID, cfg).DeadLetterPolicy,
+		DeadLetterProjectID: dlqProjectID,
+		DeadLetterTopicID:   dlqTopicID,
+		Subscriptions:       subscriptions,
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode dead letter as JSON"))
+		http.Error(w, "could not encode dead letter as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "deadletter.json", time.Time{}, bytes.NewReader(bts))
+}
+
+// SubscribeDeadLetter streams the messages which were dead lettered by a subscription. They are peeked at by default,
+// so they stay available for redriving.
+func (srv *Server) SubscribeDeadLetter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	mode := StreamMode(getQueryParamOrDefault(r.URL.Query(), queryParamKeyMode, string(StreamModePeek)))
+	if mode != StreamModePeek && mode != StreamModeDrain {
+		http.Error(w, fmt.Sprintf("invalid mode %q", mode), http.StatusBadRequest)
+		return
+	}
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	dlqClient, dlqTopic, _, ok := srv.deadLetterTopic(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	dlqSubscriptionID := getQueryParamOrDefault(r.URL.Query(), queryParamKeySubscription, "")
+	dlqSub, ok := deadLetterSubscription(ctx, w, dlqClient, dlqTopic, dlqSubscriptionID)
+	if !ok {
+		return
+	}
+
+	if mode == StreamModePeek {
+		dlqCfg, err := dlqSub.Config(ctx)
+		if err != nil {
+			handleGoogleError(w, fmt.Sprintf("get config of subscription %q", dlqSub.ID()), err)
+			return
+		}
+		if !peekAllowed(w, r, dlqSub.ID(), dlqCfg) {
+			return
+		}
+	}
+
+	// Dead lettered messages are the messages of the source topic, so they are decoded the same way.
+	decoder, err := srv.streamDecoder(ctx, r.URL.Query(), projectID, client.Topic(topicID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logWithPrefix(
+		"server: streaming dead letter subscription %q of subscription %q in project %q in %s mode",
+		dlqSub.ID(),
+		subscriptionID,
+		projectID,
+		mode,
+	)
+
+	srv.stream(w, r, dlqSub, mode, decoder)
+}
+
+// Redrive republishes dead lettered messages to the topic of the subscription which dead lettered them.
+func (srv *Server) Redrive(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req redriveRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode redrive request", http.StatusBadRequest)
+		return
+	}
+
+	idleTimeout := timeoutRedriveIdle
+	if req.IdleTimeout > 0 {
+		idleTimeout = time.Duration(req.IdleTimeout)
+	}
+
+	dlqClient, dlqTopic, _, ok := srv.deadLetterTopic(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	dlqSub, ok := deadLetterSubscription(ctx, w, dlqClient, dlqTopic, req.Subscription)
+	if !ok {
+		return
+	}
+
+	// Any of the dead lettered messages may carry an ordering key, which can only be published when message ordering
+	// is enabled before the first publish.
+	topic := client.Topic(topicID)
+	topic.EnableMessageOrdering = true
+	defer topic.Stop()
+
+	results, err := redrive(ctx, dlqSub, topic, req.MessageIDs, idleTimeout)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("redrive dead letter subscription %q", dlqSub.ID()), err)
+		return
+	}
+
+	res := redriveResponse{Results: results}
+	for _, result := range results {
+		if result.Error == "" {
+			res.Redriven++
+		} else {
+			res.Failed++
+		}
+	}
+
+	logWithPrefix(
+		"server: redrove %d messages from dead letter subscription %q to topic %q in project %q, %d failed",
+		res.Redriven,
+		dlqSub.ID(),
+		topicID,
+		projectID,
+		res.Failed,
+	)
+
+	bts, err := json.Marshal(res)
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode redrive response as JSON"))
+		http.Error(w, "could not encode redrive response as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "redrive.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func writeRetention(
+	w http.ResponseWriter,
+	r *http.Request,
+	projectID string,
+	subscriptionID string,
+	cfg pubsub.SubscriptionConfig,
+) {
+	bts, err := json.Marshal(retentionResponse{
+		ProjectID:      projectID,
+		SubscriptionID: subscriptionID,
+		Retention:      retentionFromConfig(cfg, time.Now()),
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode retention as JSON"))
+		http.Error(w, "could not encode retention as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "retention.json", time.Time{}, bytes.NewReader(bts))
+}
+
+// GetRetention shows how far back a subscription can be sought.
+func (srv *Server) GetRetention(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	_, cfg, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	writeRetention(w, r, projectID, subscriptionID, cfg)
+}
+
+// Seek seeks a subscription to a point in time or to a snapshot, after which messages are redelivered from there.
+func (srv *Server) Seek(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+	subscriptionID := chi.URLParam(r, "subscriptionID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req seekRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode seek request", http.StatusBadRequest)
+		return
+	}
+
+	err = req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, cfg, ok := topicSubscriptionConfig(ctx, w, client, topicID, subscriptionID)
+	if !ok {
+		return
+	}
+
+	if req.Time != nil {
+		err = sub.SeekToTime(ctx, *req.Time)
+		if err != nil {
+			handleGoogleError(w, fmt.Sprintf("seek subscription %q to %s", subscriptionID, req.Time), err)
+			return
+		}
+
+		logWithPrefix("server: sought subscription %q in project %q to %s", subscriptionID, projectID, req.Time)
+	} else {
+		err = sub.SeekToSnapshot(ctx, client.Snapshot(req.Snapshot))
+		if err != nil {
+			handleGoogleError(w, fmt.Sprintf("seek subscription %q to snapshot %q", subscriptionID, req.Snapshot), err)
+			return
+		}
+
+		logWithPrefix(
+			"server: sought subscription %q in project %q to snapshot %q",
+			subscriptionID,
+			projectID,
+			req.Snapshot,
+		)
+	}
+
+	writeRetention(w, r, projectID, subscriptionID, cfg)
+}
+
+func (srv *Server) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+
+	page, pageSize, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	snapshots := make([]Snapshot, 0)
+
+	snapIt := client.Snapshots(ctx)
+	for {
+		cfg, err := snapIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			handleGoogleError(w, "list snapshots", err)
+			return
+		}
+
+		snapshots = append(snapshots, snapshotFromConfig(projectID, *cfg))
+	}
+
+	pageSnapshots, totalItems, totalPages := paginate(snapshots, page, pageSize)
+
+	bts, err := json.Marshal(listSnapshotsResponse{
+		ProjectID:  projectID,
+		Snapshots:  pageSnapshots,
+		TotalItems: totalItems,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode snapshots as JSON"))
+		http.Error(w, "could not encode snapshots as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "snapshots.json", time.Time{}, bytes.NewReader(bts))
+}
+
+// CreateSnapshot creates a snapshot of the unacked messages of a subscription. Without a name the snapshot gets a
+// generated one.
+func (srv *Server) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req createSnapshotRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode create snapshot request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Subscription == "" {
+		http.Error(w, "a subscription is required", http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := client.Subscription(req.Subscription).CreateSnapshot(ctx, req.Name)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("create snapshot of subscription %q", req.Subscription), err)
+		return
+	}
+
+	logWithPrefix(
+		"server: created snapshot %q of subscription %q in project %q",
+		cfg.ID(),
+		req.Subscription,
+		projectID,
+	)
+
+	bts, err := json.Marshal(snapshotResponse{
+		Snapshot: snapshotFromConfig(projectID, *cfg),
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode create snapshot response as JSON"))
+		http.Error(w, "could not encode create snapshot response as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "snapshot.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	snapshotID := chi.URLParam(r, "snapshotID")
+
+	client, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	err := client.Snapshot(snapshotID).Delete(ctx)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("delete snapshot %q in project %q", snapshotID, projectID), err)
+		return
+	}
+
+	logWithPrefix("server: deleted snapshot %q in project %q", snapshotID, projectID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetProtobufType registers the protobuf message type of the messages on a topic, from an uploaded binary
+// FileDescriptorSet and the full name of the message type.
+func (srv *Server) SetProtobufType(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "projectID")
+	topicID := chi.URLParam(r, "topicID")
+
+	_, ok := srv.client(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile(formFieldKeyFile)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not read uploaded file %q", formFieldKeyFile), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		body = file
+	}
+
+	bts, err := io.ReadAll(body)
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not read file descriptor set"))
+		http.Error(w, "could not read file descriptor set", http.StatusInternalServerError)
+		return
+	}
+
+	md, err := parseDescriptorSet(bts, r.FormValue(formFieldKeyMessageType))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	topicName := topicNameFromTopicID(topicID)
+	srv.setUploadedProtobufType(projectID, topicName, md)
+
+	logWithPrefix("server: registered protobuf type %q for topic %q in project %q", md.FullName(), topicID, projectID)
+
+	bts, err = json.Marshal(protobufTypeResponse{
+		ProjectID:   projectID,
+		TopicID:     topicID,
+		MessageType: string(md.FullName()),
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode protobuf type as JSON"))
+		http.Error(w, "could not encode protobuf type as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "protobuf.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+
+	page, pageSize, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	schemaClient, ok := srv.schemaClient(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no schema client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	schemas := make([]Schema, 0)
+
+	schemaIt := schemaClient.Schemas(ctx, pubsub.SchemaViewFull)
+	for {
+		cfg, err := schemaIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			handleGoogleError(w, "list schemas", err)
+			return
+		}
+
+		schemas = append(schemas, schemaFromConfig(projectID, *cfg))
+	}
+
+	pageSchemas, totalItems, totalPages := paginate(schemas, page, pageSize)
+
+	bts, err := json.Marshal(listSchemasResponse{
+		ProjectID:  projectID,
+		Schemas:    pageSchemas,
+		TotalItems: totalItems,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode schemas as JSON"))
+		http.Error(w, "could not encode schemas as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "schemas.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) CreateSchema(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+
+	schemaClient, ok := srv.schemaClient(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no schema client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req createSchemaRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode create schema request", http.StatusBadRequest)
+		return
+	}
+
+	schema := Schema{
+		Name:       req.Name,
+		ProjectID:  projectID,
+		Type:       req.Type,
+		Definition: req.Definition,
+	}
+
+	err = schema.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := schema.schemaConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := schemaClient.CreateSchema(ctx, req.Name, cfg)
+	if err != nil {
+		handleGoogleError(w, "create schema", err)
+		return
+	}
+
+	logWithPrefix("server: created schema %q in project %q", req.Name, projectID)
+
+	bts, err := json.Marshal(schemaResponse{
+		Schema: schemaFromConfig(projectID, *created),
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode create schema response as JSON"))
+		http.Error(w, "could not encode create schema response as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "schema.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) GetSchema(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	schemaID := chi.URLParam(r, "schemaID")
+
+	schemaClient, ok := srv.schemaClient(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no schema client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := schemaClient.Schema(ctx, schemaID, pubsub.SchemaViewFull)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("get schema %q in project %q", schemaID, projectID), err)
+		return
+	}
+
+	bts, err := json.Marshal(schemaResponse{
+		Schema: schemaFromConfig(projectID, *cfg),
+	})
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode schema as JSON"))
+		http.Error(w, "could not encode schema as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "schema.json", time.Time{}, bytes.NewReader(bts))
+}
+
+func (srv *Server) DeleteSchema(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	schemaID := chi.URLParam(r, "schemaID")
+
+	schemaClient, ok := srv.schemaClient(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no schema client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	err := schemaClient.DeleteSchema(ctx, schemaID)
+	if err != nil {
+		handleGoogleError(w, fmt.Sprintf("delete schema %q in project %q", schemaID, projectID), err)
+		return
+	}
+
+	logWithPrefix("server: deleted schema %q in project %q", schemaID, projectID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ValidateMessage validates a sample message against a schema. A message which does not match the schema is not an
+// error of the request, so it is reported in the response instead.
+func (srv *Server) ValidateMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projectID := chi.URLParam(r, "projectID")
+	schemaID := chi.URLParam(r, "schemaID")
+
+	schemaClient, ok := srv.schemaClient(projectID)
+	if !ok {
+		logWithPrefix("server: %+v", errors.Errorf("no schema client configured for project %q", projectID))
+		http.Error(w, fmt.Sprintf("project %q not supported", projectID), http.StatusBadRequest)
+		return
+	}
+
+	var req validateMessageRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "could not decode validate message request", http.StatusBadRequest)
+		return
+	}
+
+	msg, encoding, err := req.message()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := validateMessageResponse{Valid: true}
+
+	_, err = schemaClient.ValidateMessageWithID(ctx, msg, encoding, schemaID)
+	if status.Code(err) == codes.InvalidArgument {
+		resp = validateMessageResponse{
+			Valid: false,
+			Error: status.Convert(err).Message(),
+		}
+	} else if err != nil {
+		handleGoogleError(w, fmt.Sprintf("validate message against schema %q in project %q", schemaID, projectID), err)
+		return
+	}
+
+	bts, err := json.Marshal(resp)
+	if err != nil {
+		logWithPrefix("server: %+v", errors.Wrap(err, "could not encode validate message response as JSON"))
+		http.Error(w, "could not encode validate message response as JSON", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, "validation.json", time.Time{}, bytes.NewReader(bts))
 }
 
 func (srv *Server) Start(ctx context.Context, host string, port uint) error {
@@ -617,11 +2667,44 @@ func (srv *Server) Start(ctx context.Context, host string, port uint) error {
 	r.Get("/healthy", srv.Healthy)
 	r.Get("/ready", srv.Ready)
 	r.Get("/api/projects", srv.ListProjects)
+	r.Delete("/api/cache/topics", srv.InvalidateAllTopicsCaches)
+	r.Get("/api/plan", srv.Plan)
 	r.Post("/api/projects/{projectID}/topics", srv.CreateTopic)
 	r.Get("/api/projects/{projectID}/topics", srv.ListTopics)
+	r.Delete("/api/projects/{projectID}/cache/topics", srv.InvalidateTopicsCache)
 	r.Post("/api/projects/{projectID}/topics/{topicID}", srv.Publish)
 	r.Get("/api/projects/{projectID}/topics/{topicID}", srv.Subscribe)
+	r.Patch("/api/projects/{projectID}/topics/{topicID}", srv.UpdateTopic)
+	r.Delete("/api/projects/{projectID}/topics/{topicID}", srv.DeleteTopic)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/ws", srv.SubscribeWebSocket)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/config", srv.GetTopicConfig)
+	r.Post("/api/projects/{projectID}/topics/{topicID}/messages", srv.PublishStructured)
+	r.Post("/api/projects/{projectID}/topics/{topicID}/messages/batch", srv.PublishBatch)
+	r.Put("/api/projects/{projectID}/topics/{topicID}/protobuf", srv.SetProtobufType)
 	r.Post("/api/projects/{projectID}/topics/{topicID}/subscriptions", srv.CreateSubscription)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/subscriptions", srv.ListTopicSubscriptions)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}", srv.GetSubscription)
+	r.Patch("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}", srv.UpdateSubscription)
+	r.Delete("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}", srv.DeleteSubscription)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}/messages", srv.SubscribeExisting)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}/ws", srv.SubscribeExistingWebSocket)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}/seek", srv.GetRetention)
+	r.Get("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}/deadletter", srv.GetDeadLetter)
+	r.Get(
+		"/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}/deadletter/messages",
+		srv.SubscribeDeadLetter,
+	)
+	r.Post("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}/deadletter/redrive", srv.Redrive)
+	r.Post("/api/projects/{projectID}/topics/{topicID}/subscriptions/{subscriptionID}/seek", srv.Seek)
+	r.Get("/api/projects/{projectID}/subscriptions", srv.ListSubscriptions)
+	r.Post("/api/projects/{projectID}/snapshots", srv.CreateSnapshot)
+	r.Get("/api/projects/{projectID}/snapshots", srv.ListSnapshots)
+	r.Delete("/api/projects/{projectID}/snapshots/{snapshotID}", srv.DeleteSnapshot)
+	r.Post("/api/projects/{projectID}/schemas", srv.CreateSchema)
+	r.Get("/api/projects/{projectID}/schemas", srv.ListSchemas)
+	r.Get("/api/projects/{projectID}/schemas/{schemaID}", srv.GetSchema)
+	r.Delete("/api/projects/{projectID}/schemas/{schemaID}", srv.DeleteSchema)
+	r.Post("/api/projects/{projectID}/schemas/{schemaID}/validate", srv.ValidateMessage)
 
 	for _, cfgFn := range srv.additionalRouterConfigs {
 		cfgFn(r)
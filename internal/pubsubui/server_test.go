@@ -0,0 +1,110 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        url.Values
+		wantPage     uint
+		wantPageSize uint
+		wantErr      bool
+	}{
+		{name: "defaults", query: url.Values{}, wantPage: 1, wantPageSize: 10},
+		{name: "explicit", query: url.Values{"page": {"3"}, "pageSize": {"25"}}, wantPage: 3, wantPageSize: 25},
+		{name: "zero page", query: url.Values{"page": {"0"}}, wantErr: true},
+		{name: "negative page", query: url.Values{"page": {"-1"}}, wantErr: true},
+		{name: "invalid page", query: url.Values{"page": {"first"}}, wantErr: true},
+		{name: "empty page", query: url.Values{"page": {""}}, wantErr: true},
+		{name: "zero page size", query: url.Values{"pageSize": {"0"}}, wantErr: true},
+		{name: "invalid page size", query: url.Values{"pageSize": {"many"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, pageSize, err := parsePagination(tt.query)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got page %d and page size %d", page, pageSize)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if page != tt.wantPage || pageSize != tt.wantPageSize {
+				t.Errorf(
+					"expected page %d and page size %d, got %d and %d",
+					tt.wantPage,
+					tt.wantPageSize,
+					page,
+					pageSize,
+				)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name           string
+		items          []int
+		page           uint
+		pageSize       uint
+		want           []int
+		wantTotalItems uint
+		wantTotalPages uint
+	}{
+		{name: "first page", items: items, page: 1, pageSize: 2, want: []int{1, 2}, wantTotalItems: 5, wantTotalPages: 3},
+		{name: "last page", items: items, page: 3, pageSize: 2, want: []int{5}, wantTotalItems: 5, wantTotalPages: 3},
+		{name: "past last page", items: items, page: 4, pageSize: 2, want: []int{}, wantTotalItems: 5, wantTotalPages: 3},
+		{name: "single page", items: items, page: 1, pageSize: 10, want: items, wantTotalItems: 5, wantTotalPages: 1},
+		{
+			name:           "exact pages",
+			items:          items[:4],
+			page:           2,
+			pageSize:       2,
+			want:           []int{3, 4},
+			wantTotalItems: 4,
+			wantTotalPages: 2,
+		},
+		{name: "no items", items: []int{}, page: 1, pageSize: 10, want: []int{}, wantTotalItems: 0, wantTotalPages: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, totalItems, totalPages := paginate(tt.items, tt.page, tt.pageSize)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected items %v, got %v", tt.want, got)
+			}
+			if totalItems != tt.wantTotalItems {
+				t.Errorf("expected %d total items, got %d", tt.wantTotalItems, totalItems)
+			}
+			if totalPages != tt.wantTotalPages {
+				t.Errorf("expected %d total pages, got %d", tt.wantTotalPages, totalPages)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pkg/errors"
+)
+
+type Snapshot struct {
+	Name       string    `json:"name"`
+	ProjectID  string    `json:"projectId"`
+	TopicID    string    `json:"topicId"`
+	Expiration time.Time `json:"expiration"`
+}
+
+func snapshotFromConfig(projectID string, cfg pubsub.SnapshotConfig) Snapshot {
+	snapshot := Snapshot{
+		Name:       cfg.ID(),
+		ProjectID:  projectID,
+		Expiration: cfg.Expiration,
+	}
+
+	if cfg.Topic != nil {
+		snapshot.TopicID = topicNameFromTopicID(cfg.Topic.String())
+	}
+
+	return snapshot
+}
+
+// Retention describes how far back a subscription can be sought. Messages published within the retention duration of
+// the topic can always be sought to. Otherwise only unacked messages within the retention duration of the
+// subscription can, or acked ones as well when they are retained.
+type Retention struct {
+	RetentionDuration             Duration  `json:"retentionDuration"`
+	RetainAckedMessages           bool      `json:"retainAckedMessages"`
+	TopicMessageRetentionDuration Duration  `json:"topicMessageRetentionDuration"`
+	OldestSeekTime                time.Time `json:"oldestSeekTime"`
+}
+
+func retentionFromConfig(cfg pubsub.SubscriptionConfig, now time.Time) Retention {
+	oldest := cfg.RetentionDuration
+	if cfg.TopicMessageRetentionDuration > oldest {
+		oldest = cfg.TopicMessageRetentionDuration
+	}
+
+	return Retention{
+		RetentionDuration:             Duration(cfg.RetentionDuration),
+		RetainAckedMessages:           cfg.RetainAckedMessages,
+		TopicMessageRetentionDuration: Duration(cfg.TopicMessageRetentionDuration),
+		OldestSeekTime:                now.Add(-oldest),
+	}
+}
+
+// seekRequest seeks a subscription either to a point in time or to a snapshot.
+type seekRequest struct {
+	Time     *time.Time `json:"time,omitempty"`
+	Snapshot string     `json:"snapshot,omitempty"`
+}
+
+func (req seekRequest) validate() error {
+	if (req.Time == nil) == (req.Snapshot == "") {
+		return errors.New("either a time or a snapshot is required, but not both")
+	}
+
+	return nil
+}
+
+type createSnapshotRequest struct {
+	Name         string `json:"name"`
+	Subscription string `json:"subscription"`
+}
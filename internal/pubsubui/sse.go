@@ -19,17 +19,27 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/pkg/errors"
 )
 
+// PubSubMessage is a message as it is streamed to clients. Data holds JSON when Encoding is "json", either because
+// the message was JSON already or because it was decoded using a schema. Otherwise Data is a string, holding either
+// the text of the message when Encoding is "text" or the base64 encoded bytes of the message when it is "base64".
+// DeliveryAttempt is only known for subscriptions with a dead letter policy. Dead lettered messages carry their
+// original attributes, with the attributes added by dead lettering moved to DeadLetterSource.
 type PubSubMessage struct {
-	ID          string            `json:"id"`
-	Data        json.RawMessage   `json:"data"`
-	PublishTime time.Time         `json:"publishTime"`
-	Attributes  map[string]string `json:"attributes,omitempty"`
+	ID               string            `json:"id"`
+	Data             json.RawMessage   `json:"data"`
+	Encoding         string            `json:"encoding"`
+	DecodeError      string            `json:"decodeError,omitempty"`
+	PublishTime      time.Time         `json:"publishTime"`
+	Attributes       map[string]string `json:"attributes,omitempty"`
+	DeliveryAttempt  *int              `json:"deliveryAttempt,omitempty"`
+	DeadLetterSource *DeadLetterSource `json:"deadLetterSource,omitempty"`
 }
 
 type SSEEvent struct {
@@ -38,13 +48,37 @@ type SSEEvent struct {
 	Data  []byte
 }
 
-func sseEventFromPubSubMessage(msg *pubsub.Message) (*SSEEvent, error) {
-	bts, err := json.Marshal(&PubSubMessage{
-		ID:          msg.ID,
-		Data:        msg.Data,
-		PublishTime: msg.PublishTime,
-		Attributes:  msg.Attributes,
-	})
+func pubSubMessageFromMessage(msg *pubsub.Message, decoder messageDecoder) (PubSubMessage, error) {
+	data, encoding, decodeErr := messageData(msg.Data, decoder)
+	if data == nil {
+		return PubSubMessage{}, errors.Wrap(decodeErr, "could not convert pubsub message data")
+	}
+
+	attrs, deadLetterSource := splitDeadLetterAttributes(msg.Attributes)
+
+	psMsg := PubSubMessage{
+		ID:               msg.ID,
+		Data:             data,
+		Encoding:         encoding,
+		PublishTime:      msg.PublishTime,
+		Attributes:       attrs,
+		DeliveryAttempt:  msg.DeliveryAttempt,
+		DeadLetterSource: deadLetterSource,
+	}
+	if decodeErr != nil {
+		psMsg.DecodeError = decodeErr.Error()
+	}
+
+	return psMsg, nil
+}
+
+func sseEventFromPubSubMessage(msg *pubsub.Message, decoder messageDecoder) (*SSEEvent, error) {
+	psMsg, err := pubSubMessageFromMessage(msg, decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	bts, err := json.Marshal(&psMsg)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not marshal pubsub message to JSON")
 	}
@@ -70,9 +104,15 @@ func (ssee SSEEvent) String() string {
 		sb.WriteString("\n")
 	}
 	if ssee.Data != nil {
-		sb.WriteString("data: ")
-		sb.WriteString(strings.ReplaceAll(string(ssee.Data), "\n", ""))
-		sb.WriteString("\n")
+		// Every line of the data gets its own data field, clients join them back together with newlines.
+		data := strings.ReplaceAll(string(ssee.Data), "\r\n", "\n")
+		data = strings.ReplaceAll(data, "\r", "\n")
+
+		for _, line := range strings.Split(data, "\n") {
+			sb.WriteString("data: ")
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
 	}
 
 	sb.WriteString("\n")
@@ -82,36 +122,372 @@ func (ssee SSEEvent) String() string {
 
 type SSEClient = chan SSEEvent
 
+// sseClientBufferSize is the number of events a client of a shared stream can lag behind before events are dropped.
+const sseClientBufferSize = 64
+
+// sseStreamBufferSize is the number of recent events a shared stream keeps, to replay them to reconnecting clients.
+const sseStreamBufferSize = 1000
+
+// sseResetEvent tells a reconnecting client that the events it missed cannot be replayed, because the event it last
+// received is unknown to the stream. Either it is no longer buffered or the stream was started anew.
+var sseResetEvent = SSEEvent{
+	Event: "reset",
+	Data:  []byte(`{"reason":"missed events cannot be replayed"}`),
+}
+
+// sseStreamGracePeriod is how long a shared stream keeps receiving after its last client left, so a client which
+// reconnects within it can resume where it left off.
+var sseStreamGracePeriod = time.Second * 30
+
+// eventRing is a ring buffer holding the most recent events of a stream.
+type eventRing struct {
+	events []SSEEvent
+	next   int
+	full   bool
+}
+
+func newEventRing(size int) *eventRing {
+	return &eventRing{
+		events: make([]SSEEvent, size),
+	}
+}
+
+func (ring *eventRing) add(event SSEEvent) {
+	ring.events[ring.next] = event
+
+	ring.next = (ring.next + 1) % len(ring.events)
+	if ring.next == 0 {
+		ring.full = true
+	}
+}
+
+// after returns the events following the event with the given ID, oldest first. It returns false when the ID is not in
+// the buffer, in which case what the client missed is unknown.
+func (ring *eventRing) after(id string) ([]SSEEvent, bool) {
+	var events []SSEEvent
+	if ring.full {
+		events = append(events, ring.events[ring.next:]...)
+	}
+	events = append(events, ring.events[:ring.next]...)
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].ID == id {
+			return events[i+1:], true
+		}
+	}
+
+	return nil, false
+}
+
+// StreamMode determines what happens to a message after it has been streamed to a client.
+type StreamMode string
+
+const (
+	// StreamModePeek nacks every message so it will be redelivered to the actual consumers of the subscription.
+	StreamModePeek StreamMode = "peek"
+	// StreamModeDrain acks every message, removing it from the subscription.
+	StreamModeDrain StreamMode = "drain"
+)
+
+// peekSeenIDsSize is the number of recently peeked message IDs which are remembered, so redelivered messages are not
+// streamed to the client again.
+const peekSeenIDsSize = 1000
+
+// While peeking, messages are never leased for longer than it takes to stream them.
+const (
+	peekMaxExtension       = time.Second * 30
+	peekMaxExtensionPeriod = time.Second * 10
+)
+
+// ServerSSE streams messages to clients using server-sent events. Clients watching the same topic share a stream: the
+// first client creates the subscription, the messages it receives are broadcast to every client of the stream and the
+// subscription is deleted when no client has been connected for the grace period. Clients reconnecting with the ID of
+// the last event they received get the events they missed replayed.
 type ServerSSE struct {
-	subscribeCh   chan SSEClient
-	unSubscribeCh chan SSEClient
+	ctx context.Context
+
+	mu            sync.Mutex
+	streams       map[string]*sharedStream
+	subscriptions map[string]bool
+}
+
+func newServerSSE(ctx context.Context) *ServerSSE {
+	return &ServerSSE{
+		ctx:           ctx,
+		streams:       make(map[string]*sharedStream),
+		subscriptions: make(map[string]bool),
+	}
 }
 
-func (srv *ServerSSE) handle(ctx context.Context) {
-	clients := make(map[SSEClient]bool)
+// activate marks a subscription as being streamed from. This has to happen before the subscription is created, so the
+// janitor never mistakes it for an orphaned one.
+func (srv *ServerSSE) activate(subscriptionID string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
 
+	srv.subscriptions[subscriptionID] = true
+}
+
+func (srv *ServerSSE) deactivate(subscriptionID string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	delete(srv.subscriptions, subscriptionID)
+}
+
+func (srv *ServerSSE) active(subscriptionID string) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.subscriptions[subscriptionID]
+}
+
+// sharedStream receives the messages of a single subscription on behalf of all of its clients. Ready is closed once the
+// subscription of the stream was created, or creating it failed with err.
+type sharedStream struct {
+	key   string
+	ready chan struct{}
+	err   error
+
+	mu      sync.Mutex
+	clients map[SSEClient]bool
+	events  *eventRing
+	closed  bool
+	cancel  context.CancelFunc
+	grace   *time.Timer
+}
+
+func (stream *sharedStream) broadcast(event SSEEvent) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	stream.events.add(event)
+
+	for client := range stream.clients {
+		select {
+		case client <- event:
+		default:
+			logWithPrefix("sse: dropped message %q for a slow client of stream %q", event.ID, stream.key)
+		}
+	}
+}
+
+// close disconnects all clients. It must be called with the lock of the stream held.
+func (stream *sharedStream) close() {
+	stream.closed = true
+
+	if stream.grace != nil {
+		stream.grace.Stop()
+	}
+
+	for client := range stream.clients {
+		close(client)
+		delete(stream.clients, client)
+	}
+
+	if stream.cancel != nil {
+		stream.cancel()
+	}
+}
+
+// join adds a client to the stream with the given key, creating the stream and its subscription using create when it
+// does not exist yet. Clients joining while the subscription is being created wait for it, until ctx is done. When the
+// client passes the ID of the last event it received, the buffered events following it are returned, to be sent
+// before the events of the client. The returned function removes the client again and must always be called.
+func (srv *ServerSSE) join(
+	ctx context.Context,
+	key string,
+	lastEventID string,
+	decoder messageDecoder,
+	create func(context.Context) (*pubsub.Subscription, error),
+) (SSEClient, []SSEEvent, func(), error) {
 	for {
+		srv.mu.Lock()
+		stream, ok := srv.streams[key]
+		if !ok {
+			stream = &sharedStream{
+				key:     key,
+				ready:   make(chan struct{}),
+				clients: make(map[SSEClient]bool),
+				events:  newEventRing(sseStreamBufferSize),
+			}
+			srv.streams[key] = stream
+
+			go srv.start(stream, decoder, create)
+		}
+		srv.mu.Unlock()
+
 		select {
-		case sub := <-srv.subscribeCh:
-			clients[sub] = true
-		case unsub := <-srv.unSubscribeCh:
-			delete(clients, unsub)
+		case <-stream.ready:
 		case <-ctx.Done():
-			for client := range clients {
-				close(client)
-				delete(clients, client)
+			return nil, nil, nil, ctx.Err()
+		}
+
+		if stream.err != nil {
+			return nil, nil, nil, stream.err
+		}
+
+		stream.mu.Lock()
+
+		// The stream was torn down between looking it up and locking it, so look up or create a new one.
+		if stream.closed {
+			stream.mu.Unlock()
+			continue
+		}
+
+		if stream.grace != nil {
+			stream.grace.Stop()
+			stream.grace = nil
+		}
+
+		var replay []SSEEvent
+		if lastEventID != "" {
+			events, ok := stream.events.after(lastEventID)
+			if ok {
+				replay = events
+				logWithPrefix("sse: replaying %d events of stream %q after %q", len(replay), key, lastEventID)
+			} else {
+				replay = []SSEEvent{sseResetEvent}
+				logWithPrefix("sse: cannot replay events of stream %q after unknown %q", key, lastEventID)
 			}
 		}
+
+		client := make(SSEClient, sseClientBufferSize)
+		stream.clients[client] = true
+
+		stream.mu.Unlock()
+
+		return client, replay, func() {
+			srv.leave(stream, client)
+		}, nil
 	}
 }
 
-func (srv *ServerSSE) Subscribe(w http.ResponseWriter, r *http.Request, messageCh chan *pubsub.Message) {
-	ctx := r.Context()
+// start creates the subscription of the stream and starts receiving from it. The subscription is created on behalf of
+// all clients of the stream, so it is not cancelled when the client which caused its creation disconnects.
+func (srv *ServerSSE) start(
+	stream *sharedStream,
+	decoder messageDecoder,
+	create func(context.Context) (*pubsub.Subscription, error),
+) {
+	createCtx, cancelCreate := context.WithTimeout(srv.ctx, timeoutSubscriptionCreation)
+	sub, err := create(createCtx)
+	cancelCreate()
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if err != nil {
+		stream.err = err
+		stream.closed = true
+		srv.remove(stream)
+		close(stream.ready)
 
+		return
+	}
+
+	ctx, cancel := context.WithCancel(srv.ctx)
+	stream.cancel = cancel
+
+	// Every client waiting for the stream may have left in the meantime, in which case the grace period makes sure the
+	// stream still expires. The first client to join stops it.
+	stream.grace = time.AfterFunc(sseStreamGracePeriod, func() {
+		srv.expire(stream)
+	})
+
+	close(stream.ready)
+
+	go srv.receive(ctx, stream, sub, decoder)
+}
+
+func (srv *ServerSSE) leave(stream *sharedStream, client SSEClient) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if !stream.clients[client] {
+		return
+	}
+
+	delete(stream.clients, client)
+	close(client)
+
+	if len(stream.clients) == 0 {
+		stream.grace = time.AfterFunc(sseStreamGracePeriod, func() {
+			srv.expire(stream)
+		})
+	}
+}
+
+// expire tears the stream down when no client joined it during the grace period.
+func (srv *ServerSSE) expire(stream *sharedStream) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.closed || len(stream.clients) > 0 {
+		return
+	}
+
+	stream.close()
+	srv.remove(stream)
+}
+
+func (srv *ServerSSE) remove(stream *sharedStream) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.streams[stream.key] == stream {
+		delete(srv.streams, stream.key)
+	}
+}
+
+// receive broadcasts the messages of the subscription until the stream expires, after which the subscription is
+// deleted. Every message is acked once broadcast, since the subscription only exists for the clients of the stream.
+func (srv *ServerSSE) receive(
+	ctx context.Context,
+	stream *sharedStream,
+	sub *pubsub.Subscription,
+	decoder messageDecoder,
+) {
+	logWithPrefix("sse: started stream %q", stream.key)
+
+	err := sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		event, err := sseEventFromPubSubMessage(msg, decoder)
+		if err != nil {
+			logWithPrefix("sse: could not convert pubsub message to SSE event: %+v", err)
+			msg.Ack()
+			return
+		}
+
+		stream.broadcast(*event)
+		msg.Ack()
+	})
+	if err != nil {
+		logWithPrefix("sse: stream %q stopped receiving: %+v", stream.key, err)
+	}
+
+	// Receiving can also stop on its own, for instance when the subscription was deleted elsewhere, in which case the
+	// remaining clients are disconnected.
+	stream.mu.Lock()
+	stream.close()
+	srv.remove(stream)
+	stream.mu.Unlock()
+
+	// A subscription which cannot be deleted is left to the janitor.
+	err = sub.Delete(context.Background())
+	if err != nil {
+		logWithPrefix("sse: could not delete subscription of stream %q: %+v", stream.key, err)
+	}
+
+	srv.deactivate(sub.ID())
+
+	logWithPrefix("sse: stopped stream %q", stream.key)
+}
+
+func streamHeaders(w http.ResponseWriter) (http.Flusher, bool) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
-		return
+		return nil, false
 	}
 
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -119,25 +495,116 @@ func (srv *ServerSSE) Subscribe(w http.ResponseWriter, r *http.Request, messageC
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	client := make(SSEClient)
-	srv.subscribeCh <- client
-	defer func() {
-		srv.unSubscribeCh <- client
-	}()
+	return flusher, true
+}
+
+// Serve writes the replayed events and then the events of a shared stream to the client until either disconnects.
+func (srv *ServerSSE) Serve(w http.ResponseWriter, r *http.Request, client SSEClient, replay []SSEEvent) {
+	ctx := r.Context()
+
+	flusher, ok := streamHeaders(w)
+	if !ok {
+		return
+	}
+
+	for _, event := range replay {
+		w.Write([]byte(event.String()))
+	}
+
+	flusher.Flush()
+
+	for {
+		select {
+		case event, ok := <-client:
+			if !ok {
+				return
+			}
+
+			w.Write([]byte(event.String()))
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recentIDs is a set holding at most limit IDs. When it is full, adding an ID evicts the ID which was added first.
+type recentIDs struct {
+	limit int
+	order []string
+	ids   map[string]bool
+}
+
+func newRecentIDs(limit int) *recentIDs {
+	return &recentIDs{
+		limit: limit,
+		order: make([]string, 0, limit),
+		ids:   make(map[string]bool, limit),
+	}
+}
+
+// add adds the ID and tells whether it was not in the set yet.
+func (ri *recentIDs) add(id string) bool {
+	if ri.ids[id] {
+		return false
+	}
+
+	if len(ri.order) >= ri.limit {
+		delete(ri.ids, ri.order[0])
+		ri.order = ri.order[1:]
+	}
+
+	ri.order = append(ri.order, id)
+	ri.ids[id] = true
+
+	return true
+}
+
+// Subscribe streams the messages of a subscription to a single client, acking or nacking them depending on the mode.
+func (srv *ServerSSE) Subscribe(
+	w http.ResponseWriter,
+	r *http.Request,
+	messageCh chan *pubsub.Message,
+	mode StreamMode,
+	decoder messageDecoder,
+) {
+	ctx := r.Context()
+
+	flusher, ok := streamHeaders(w)
+	if !ok {
+		return
+	}
+
+	// Nacked messages are redelivered, so when peeking we keep track of the messages the client has recently seen.
+	seen := newRecentIDs(peekSeenIDsSize)
 
 	for {
 		select {
-		case msg := <-messageCh:
-			event, err := sseEventFromPubSubMessage(msg)
+		case msg, ok := <-messageCh:
+			if !ok {
+				return
+			}
+
+			if mode == StreamModePeek && !seen.add(msg.ID) {
+				msg.Nack()
+				continue
+			}
+
+			event, err := sseEventFromPubSubMessage(msg, decoder)
 			if err != nil {
 				logWithPrefix("could not convert pubsub message to SSE event: %+v\n", err)
+				msg.Nack()
 				continue
 			}
 
 			w.Write([]byte(event.String()))
 			flusher.Flush()
 
-			msg.Ack()
+			if mode == StreamModePeek {
+				msg.Nack()
+			} else {
+				msg.Ack()
+			}
 		case <-ctx.Done():
 			return
 		}
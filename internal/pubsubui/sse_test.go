@@ -0,0 +1,129 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestServerSSEJoinCreatesSubscriptionOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := newTestClient(t)
+
+	topic, err := client.CreateTopic(ctx, "topic")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+
+	srv := newServerSSE(ctx)
+
+	var created int32
+	release := make(chan struct{})
+	create := func(ctx context.Context) (*pubsub.Subscription, error) {
+		atomic.AddInt32(&created, 1)
+		<-release
+
+		return client.CreateSubscription(ctx, "subscription", pubsub.SubscriptionConfig{Topic: topic})
+	}
+
+	// A client which gives up while the subscription is being created does not hold up the others.
+	gaveUpCtx, giveUp := context.WithCancel(ctx)
+	giveUp()
+
+	_, _, _, err = srv.join(gaveUpCtx, "key", "", jsonDecoder{}, create)
+	if err != context.Canceled {
+		t.Fatalf("expected client which gave up to get %v, got %v", context.Canceled, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, _, leave, err := srv.join(ctx, "key", "", jsonDecoder{}, create)
+			if err != nil {
+				errs <- err
+				return
+			}
+			leave()
+		}()
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("could not join stream: %v", err)
+	}
+
+	if created := atomic.LoadInt32(&created); created != 1 {
+		t.Errorf("expected subscription to be created once, got %d", created)
+	}
+}
+
+func TestEventRingAfter(t *testing.T) {
+	events := func(ids ...string) []SSEEvent {
+		evs := make([]SSEEvent, 0, len(ids))
+		for _, id := range ids {
+			evs = append(evs, SSEEvent{ID: id})
+		}
+
+		return evs
+	}
+
+	tests := []struct {
+		name   string
+		added  []string
+		after  string
+		want   []SSEEvent
+		wantOK bool
+	}{
+		{name: "empty", after: "1", wantOK: false},
+		{name: "not full", added: []string{"1", "2"}, after: "1", want: events("2"), wantOK: true},
+		{name: "latest", added: []string{"1", "2"}, after: "2", want: events(), wantOK: true},
+		{name: "full", added: []string{"1", "2", "3"}, after: "1", want: events("2", "3"), wantOK: true},
+		{name: "wrapped", added: []string{"1", "2", "3", "4", "5"}, after: "3", want: events("4", "5"), wantOK: true},
+		{name: "overwritten", added: []string{"1", "2", "3", "4"}, after: "1", wantOK: false},
+		{name: "unknown", added: []string{"1", "2"}, after: "3", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := newEventRing(3)
+			for _, id := range tt.added {
+				ring.add(SSEEvent{ID: id})
+			}
+
+			got, ok := ring.after(tt.after)
+			if ok != tt.wantOK {
+				t.Fatalf("expected found to be %t, got %t", tt.wantOK, ok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected events %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
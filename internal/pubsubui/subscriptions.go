@@ -0,0 +1,341 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/pkg/errors"
+)
+
+// The limits Pub/Sub puts on the settings of topics and subscriptions.
+const (
+	minAckDeadline         = time.Second * 10
+	maxAckDeadline         = time.Minute * 10
+	minRetentionDuration   = time.Minute * 10
+	maxRetentionDuration   = time.Hour * 24 * 7
+	minExpirationPolicy    = time.Hour * 24
+	minMaxDeliveryAttempts = 5
+	maxMaxDeliveryAttempts = 100
+	maxBackoff             = time.Minute * 10
+)
+
+type DeadLetterPolicy struct {
+	DeadLetterTopic     string `yaml:"deadLetterTopic"     json:"deadLetterTopic"`
+	MaxDeliveryAttempts int    `yaml:"maxDeliveryAttempts" json:"maxDeliveryAttempts"`
+}
+
+// deadLetterPolicy converts the policy to a Pub/Sub dead letter policy. The dead letter topic may be given by ID, in
+// which case it is assumed to live in the project of the client.
+func (dlp DeadLetterPolicy) deadLetterPolicy(client *pubsub.Client) *pubsub.DeadLetterPolicy {
+	deadLetterTopic := dlp.DeadLetterTopic
+	if !strings.Contains(deadLetterTopic, "/") {
+		deadLetterTopic = client.Topic(deadLetterTopic).String()
+	}
+
+	return &pubsub.DeadLetterPolicy{
+		DeadLetterTopic:     deadLetterTopic,
+		MaxDeliveryAttempts: dlp.MaxDeliveryAttempts,
+	}
+}
+
+type RetryPolicy struct {
+	MinimumBackoff Duration `yaml:"minimumBackoff" json:"minimumBackoff"`
+	MaximumBackoff Duration `yaml:"maximumBackoff" json:"maximumBackoff"`
+}
+
+// retryPolicy converts the policy to a Pub/Sub retry policy. Backoffs left empty fall back to the Pub/Sub defaults.
+func (rp RetryPolicy) retryPolicy() *pubsub.RetryPolicy {
+	retryPolicy := &pubsub.RetryPolicy{}
+	if rp.MinimumBackoff != 0 {
+		retryPolicy.MinimumBackoff = time.Duration(rp.MinimumBackoff)
+	}
+	if rp.MaximumBackoff != 0 {
+		retryPolicy.MaximumBackoff = time.Duration(rp.MaximumBackoff)
+	}
+
+	return retryPolicy
+}
+
+// SubscriptionSettings holds the settings which can be provided when creating a subscription, both through the API
+// and the config file. Settings left empty fall back to the Pub/Sub defaults. Settings which are optional are pointers,
+// so a setting which was left out can be told apart from one which was explicitly set to its zero value.
+type SubscriptionSettings struct {
+	AckDeadline               Duration          `yaml:"ackDeadline"               json:"ackDeadline"`
+	RetentionDuration         Duration          `yaml:"retentionDuration"         json:"retentionDuration"`
+	RetainAckedMessages       *bool             `yaml:"retainAckedMessages"       json:"retainAckedMessages"`
+	ExpirationPolicy          *Duration         `yaml:"expirationPolicy"          json:"expirationPolicy"`
+	Filter                    *string           `yaml:"filter"                    json:"filter"`
+	EnableMessageOrdering     *bool             `yaml:"enableMessageOrdering"     json:"enableMessageOrdering"`
+	EnableExactlyOnceDelivery *bool             `yaml:"enableExactlyOnceDelivery" json:"enableExactlyOnceDelivery"`
+	DeadLetterPolicy          *DeadLetterPolicy `yaml:"deadLetterPolicy"          json:"deadLetterPolicy"`
+	RetryPolicy               *RetryPolicy      `yaml:"retryPolicy"               json:"retryPolicy"`
+	PushEndpoint              string            `yaml:"pushEndpoint"              json:"pushEndpoint"`
+	PushAttributes            map[string]string `yaml:"pushAttributes"            json:"pushAttributes"`
+	Labels                    map[string]string `yaml:"labels"                    json:"labels"`
+}
+
+// subscriptionConfig converts the settings to a subscription config for the given topic.
+func (ss SubscriptionSettings) subscriptionConfig(
+	client *pubsub.Client,
+	topic *pubsub.Topic,
+) pubsub.SubscriptionConfig {
+	cfg := pubsub.SubscriptionConfig{
+		Topic:                     topic,
+		AckDeadline:               time.Duration(ss.AckDeadline),
+		RetentionDuration:         time.Duration(ss.RetentionDuration),
+		RetainAckedMessages:       boolValue(ss.RetainAckedMessages),
+		Filter:                    stringValue(ss.Filter),
+		EnableMessageOrdering:     boolValue(ss.EnableMessageOrdering),
+		EnableExactlyOnceDelivery: boolValue(ss.EnableExactlyOnceDelivery),
+		PushConfig: pubsub.PushConfig{
+			Endpoint:   ss.PushEndpoint,
+			Attributes: ss.PushAttributes,
+		},
+		Labels: ss.Labels,
+	}
+
+	// A zero expiration policy means the subscription never expires, so only set it when explicitly configured.
+	if ss.ExpirationPolicy != nil {
+		cfg.ExpirationPolicy = time.Duration(*ss.ExpirationPolicy)
+	}
+
+	if ss.DeadLetterPolicy != nil {
+		cfg.DeadLetterPolicy = ss.DeadLetterPolicy.deadLetterPolicy(client)
+	}
+
+	if ss.RetryPolicy != nil {
+		cfg.RetryPolicy = ss.RetryPolicy.retryPolicy()
+	}
+
+	return cfg
+}
+
+// updateSubscriptionRequest holds the subscription settings to change, settings which are left out remain unchanged.
+// Labels replace the current labels. An expiration policy of 0s makes the subscription never expire, an empty dead
+// letter or retry policy removes it and an empty push endpoint turns a push subscription into a pull subscription.
+type updateSubscriptionRequest struct {
+	AckDeadline               *Duration         `json:"ackDeadline"`
+	RetentionDuration         *Duration         `json:"retentionDuration"`
+	RetainAckedMessages       *bool             `json:"retainAckedMessages"`
+	ExpirationPolicy          *Duration         `json:"expirationPolicy"`
+	EnableExactlyOnceDelivery *bool             `json:"enableExactlyOnceDelivery"`
+	DeadLetterPolicy          *DeadLetterPolicy `json:"deadLetterPolicy"`
+	RetryPolicy               *RetryPolicy      `json:"retryPolicy"`
+	PushEndpoint              *string           `json:"pushEndpoint"`
+	PushAttributes            map[string]string `json:"pushAttributes"`
+	Labels                    map[string]string `json:"labels"`
+}
+
+func (req updateSubscriptionRequest) validate() error {
+	if req.AckDeadline == nil && req.RetentionDuration == nil && req.RetainAckedMessages == nil &&
+		req.ExpirationPolicy == nil && req.EnableExactlyOnceDelivery == nil && req.DeadLetterPolicy == nil &&
+		req.RetryPolicy == nil && req.PushEndpoint == nil && req.PushAttributes == nil && req.Labels == nil {
+		return errors.New("nothing to update")
+	}
+
+	if d := req.AckDeadline; d != nil && (time.Duration(*d) < minAckDeadline || time.Duration(*d) > maxAckDeadline) {
+		return errors.Errorf("ack deadline must be between %s and %s", minAckDeadline, maxAckDeadline)
+	}
+
+	if d := req.RetentionDuration; d != nil &&
+		(time.Duration(*d) < minRetentionDuration || time.Duration(*d) > maxRetentionDuration) {
+		return errors.Errorf("retention duration must be between %s and %s", minRetentionDuration, maxRetentionDuration)
+	}
+
+	if d := req.ExpirationPolicy; d != nil && *d != 0 && time.Duration(*d) < minExpirationPolicy {
+		return errors.Errorf("expiration policy must be at least %s, or 0s to never expire", minExpirationPolicy)
+	}
+
+	if dlp := req.DeadLetterPolicy; dlp != nil && *dlp != (DeadLetterPolicy{}) {
+		if dlp.DeadLetterTopic == "" {
+			return errors.New("dead letter policy has no dead letter topic")
+		}
+		if dlp.MaxDeliveryAttempts != 0 &&
+			(dlp.MaxDeliveryAttempts < minMaxDeliveryAttempts || dlp.MaxDeliveryAttempts > maxMaxDeliveryAttempts) {
+			return errors.Errorf(
+				"max delivery attempts must be between %d and %d",
+				minMaxDeliveryAttempts,
+				maxMaxDeliveryAttempts,
+			)
+		}
+	}
+
+	if rp := req.RetryPolicy; rp != nil {
+		for _, backoff := range []Duration{rp.MinimumBackoff, rp.MaximumBackoff} {
+			if backoff < 0 || time.Duration(backoff) > maxBackoff {
+				return errors.Errorf("backoffs must be between 0s and %s", maxBackoff)
+			}
+		}
+		if rp.MinimumBackoff != 0 && rp.MaximumBackoff != 0 && rp.MinimumBackoff > rp.MaximumBackoff {
+			return errors.New("minimum backoff cannot exceed maximum backoff")
+		}
+	}
+
+	if req.PushAttributes != nil && req.PushEndpoint == nil {
+		return errors.New("push attributes can only be changed together with the push endpoint")
+	}
+
+	if req.Labels != nil {
+		err := validateLabels(req.Labels)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subscriptionConfigToUpdate converts the request to the changes to make to the deployed subscription.
+func (req updateSubscriptionRequest) subscriptionConfigToUpdate(
+	client *pubsub.Client,
+	deployed pubsub.SubscriptionConfig,
+) pubsub.SubscriptionConfigToUpdate {
+	var update pubsub.SubscriptionConfigToUpdate
+
+	if req.AckDeadline != nil {
+		update.AckDeadline = time.Duration(*req.AckDeadline)
+	}
+
+	if req.RetentionDuration != nil {
+		update.RetentionDuration = time.Duration(*req.RetentionDuration)
+	}
+
+	if req.RetainAckedMessages != nil {
+		update.RetainAckedMessages = *req.RetainAckedMessages
+	}
+
+	if req.ExpirationPolicy != nil {
+		update.ExpirationPolicy = time.Duration(*req.ExpirationPolicy)
+	}
+
+	if req.EnableExactlyOnceDelivery != nil {
+		update.EnableExactlyOnceDelivery = *req.EnableExactlyOnceDelivery
+	}
+
+	// Pub/Sub removes the dead letter and retry policies when they are updated to their zero values.
+	if req.DeadLetterPolicy != nil {
+		update.DeadLetterPolicy = &pubsub.DeadLetterPolicy{}
+		if *req.DeadLetterPolicy != (DeadLetterPolicy{}) {
+			update.DeadLetterPolicy = req.DeadLetterPolicy.deadLetterPolicy(client)
+		}
+	}
+
+	if req.RetryPolicy != nil {
+		update.RetryPolicy = req.RetryPolicy.retryPolicy()
+	}
+
+	if req.PushEndpoint != nil {
+		update.PushConfig = &pubsub.PushConfig{
+			Endpoint:   *req.PushEndpoint,
+			Attributes: req.PushAttributes,
+		}
+	}
+
+	if req.Labels != nil {
+		update.Labels = withReservedLabels(req.Labels, deployed.Labels)
+	}
+
+	return update
+}
+
+type Subscription struct {
+	ID                            string            `json:"id"`
+	Name                          string            `json:"name"`
+	ProjectID                     string            `json:"projectId"`
+	TopicID                       string            `json:"topicId"`
+	PushEndpoint                  string            `json:"pushEndpoint,omitempty"`
+	PushAttributes                map[string]string `json:"pushAttributes,omitempty"`
+	AckDeadline                   Duration          `json:"ackDeadline"`
+	RetainAckedMessages           bool              `json:"retainAckedMessages"`
+	RetentionDuration             Duration          `json:"retentionDuration"`
+	ExpirationPolicy              Duration          `json:"expirationPolicy"`
+	Labels                        map[string]string `json:"labels,omitempty"`
+	EnableMessageOrdering         bool              `json:"enableMessageOrdering"`
+	EnableExactlyOnceDelivery     bool              `json:"enableExactlyOnceDelivery"`
+	DeadLetterPolicy              *DeadLetterPolicy `json:"deadLetterPolicy,omitempty"`
+	Filter                        string            `json:"filter,omitempty"`
+	RetryPolicy                   *RetryPolicy      `json:"retryPolicy,omitempty"`
+	Detached                      bool              `json:"detached"`
+	TopicMessageRetentionDuration Duration          `json:"topicMessageRetentionDuration"`
+}
+
+// optionalDuration unwraps the optional.Duration values returned by the Pub/Sub client, which are either nil or a
+// time.Duration.
+func optionalDuration(d interface{}) Duration {
+	td, ok := d.(time.Duration)
+	if !ok {
+		return 0
+	}
+
+	return Duration(td)
+}
+
+// boolValue returns the value of an optional bool, which is false when it is not set.
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+// stringValue returns the value of an optional string, which is empty when it is not set.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func subscriptionFromConfig(projectID string, cfg pubsub.SubscriptionConfig) Subscription {
+	sub := Subscription{
+		ID:                            cfg.ID(),
+		Name:                          cfg.ID(),
+		ProjectID:                     projectID,
+		PushEndpoint:                  cfg.PushConfig.Endpoint,
+		PushAttributes:                cfg.PushConfig.Attributes,
+		AckDeadline:                   Duration(cfg.AckDeadline),
+		RetainAckedMessages:           cfg.RetainAckedMessages,
+		RetentionDuration:             Duration(cfg.RetentionDuration),
+		ExpirationPolicy:              optionalDuration(cfg.ExpirationPolicy),
+		Labels:                        cfg.Labels,
+		EnableMessageOrdering:         cfg.EnableMessageOrdering,
+		EnableExactlyOnceDelivery:     cfg.EnableExactlyOnceDelivery,
+		Filter:                        cfg.Filter,
+		Detached:                      cfg.Detached,
+		TopicMessageRetentionDuration: Duration(cfg.TopicMessageRetentionDuration),
+	}
+
+	// Topics of detached subscriptions are named "_deleted-topic_", which would make Topic.ID panic.
+	if cfg.Topic != nil {
+		sub.TopicID = topicNameFromTopicID(cfg.Topic.String())
+	}
+
+	if cfg.DeadLetterPolicy != nil {
+		sub.DeadLetterPolicy = &DeadLetterPolicy{
+			DeadLetterTopic:     cfg.DeadLetterPolicy.DeadLetterTopic,
+			MaxDeliveryAttempts: cfg.DeadLetterPolicy.MaxDeliveryAttempts,
+		}
+	}
+
+	if cfg.RetryPolicy != nil {
+		sub.RetryPolicy = &RetryPolicy{
+			MinimumBackoff: optionalDuration(cfg.RetryPolicy.MinimumBackoff),
+			MaximumBackoff: optionalDuration(cfg.RetryPolicy.MaximumBackoff),
+		}
+	}
+
+	return sub
+}
@@ -17,7 +17,10 @@ package pubsubui
 import (
 	"context"
 	"io"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/pkg/errors"
@@ -32,32 +35,260 @@ var (
 	timeoutSubscriptionCreation = time.Second * 15
 )
 
+// Topics and subscriptions created from the config file carry this label, so they can be recognized when pruning.
+const (
+	labelKeyManaged   = "pubsubui-managed"
+	labelValueManaged = "true"
+)
+
+// withManagedLabel returns a copy of the given labels with the managed label added.
+func withManagedLabel(labels map[string]string) map[string]string {
+	managed := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		managed[k] = v
+	}
+	managed[labelKeyManaged] = labelValueManaged
+
+	return managed
+}
+
+// Labels starting with this prefix are set by pubsubui itself, like the managed label.
+const labelPrefixReserved = "pubsubui-"
+
+const maxLabels = 64
+
+// withReservedLabels returns a copy of the given labels with the reserved labels of the deployed labels added, so
+// replacing the labels of a topic or subscription does not make pubsubui lose track of it.
+func withReservedLabels(labels, deployed map[string]string) map[string]string {
+	reserved := make(map[string]string, len(labels))
+	for k, v := range labels {
+		reserved[k] = v
+	}
+	for k, v := range deployed {
+		if strings.HasPrefix(k, labelPrefixReserved) {
+			reserved[k] = v
+		}
+	}
+
+	return reserved
+}
+
+// isLabelText tells whether s only holds lowercase letters, digits, underscores and dashes, and is at most 63
+// characters long.
+func isLabelText(s string) bool {
+	if utf8.RuneCountInString(s) > 63 {
+		return false
+	}
+
+	for _, r := range s {
+		if !unicode.IsLower(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateLabels checks the given labels against the rules of Pub/Sub. Keys must start with a lowercase letter and
+// the reserved prefix cannot be used.
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return errors.Errorf("at most %d labels are allowed", maxLabels)
+	}
+
+	for key, val := range labels {
+		if r, _ := utf8.DecodeRuneInString(key); !unicode.IsLower(r) || !isLabelText(key) {
+			return errors.Errorf(
+				"label key %q must start with a lowercase letter and may only hold up to 63 lowercase letters, "+
+					"digits, underscores and dashes",
+				key,
+			)
+		}
+		if strings.HasPrefix(key, labelPrefixReserved) {
+			return errors.Errorf("label key %q uses the reserved prefix %q", key, labelPrefixReserved)
+		}
+		if !isLabelText(val) {
+			return errors.Errorf(
+				"value of label %q may only hold up to 63 lowercase letters, digits, underscores and dashes",
+				key,
+			)
+		}
+	}
+
+	return nil
+}
+
 type MessagePayload struct {
 	Name    string `yaml:"name"    json:"name"`
 	Payload string `yaml:"payload" json:"payload"`
 }
 
+// TopicSubscription is a subscription entry in the config file. It is either just the name of the subscription or
+// an object holding the name and the settings of the subscription.
+type TopicSubscription struct {
+	Name                 string `yaml:"name"`
+	SubscriptionSettings `yaml:",inline"`
+}
+
+func (ts *TopicSubscription) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&ts.Name)
+	}
+
+	// Decoding into a type without methods prevents this method from calling itself.
+	type topicSubscription TopicSubscription
+
+	var tsub topicSubscription
+	err := value.Decode(&tsub)
+	if err != nil {
+		return err
+	}
+	if tsub.Name == "" {
+		return errors.Errorf("subscription on line %d has no name", value.Line)
+	}
+
+	*ts = TopicSubscription(tsub)
+
+	return nil
+}
+
 type Topic struct {
-	ID            string           `yaml:"-"             json:"id"`
-	Name          string           `yaml:"name"          json:"name"`
-	ProjectID     string           `yaml:"project"       json:"projectId"`
-	Subscriptions []string         `yaml:"subscriptions" json:"-"`
-	Payloads      []MessagePayload `yaml:"payloads"      json:"payloads"`
+	ID            string              `yaml:"-"             json:"id"`
+	Name          string              `yaml:"name"          json:"name"`
+	ProjectID     string              `yaml:"project"       json:"projectId"`
+	Labels        map[string]string   `yaml:"labels"        json:"labels,omitempty"`
+	Schema        *TopicSchema        `yaml:"schema"        json:"schema,omitempty"`
+	Protobuf      *TopicProtobuf      `yaml:"protobuf"      json:"-"`
+	Subscriptions []TopicSubscription `yaml:"subscriptions" json:"-"`
+	Payloads      []MessagePayload    `yaml:"payloads"      json:"payloads"`
 }
 
 func (t Topic) Key() string {
 	return t.ProjectID + "/" + t.Name
 }
 
+// topicFromConfig converts a topic config to a topic as listed, without payloads, since these come from the config
+// file rather than from Pub/Sub.
+func topicFromConfig(projectID string, cfg pubsub.TopicConfig) Topic {
+	return Topic{
+		ID:        cfg.ID(),
+		Name:      cfg.ID(),
+		ProjectID: projectID,
+		Labels:    cfg.Labels,
+		Schema:    topicSchemaFromSettings(cfg.SchemaSettings),
+	}
+}
+
+type MessageStoragePolicy struct {
+	AllowedPersistenceRegions []string `json:"allowedPersistenceRegions"`
+}
+
+// TopicConfig is the full configuration of a topic as known by Pub/Sub, as opposed to Topic which only holds what is
+// needed to list topics. A zero retention duration means message retention is left to the subscriptions.
+type TopicConfig struct {
+	ID                   string                `json:"id"`
+	Name                 string                `json:"name"`
+	ProjectID            string                `json:"projectId"`
+	Labels               map[string]string     `json:"labels,omitempty"`
+	RetentionDuration    Duration              `json:"retentionDuration"`
+	MessageStoragePolicy *MessageStoragePolicy `json:"messageStoragePolicy,omitempty"`
+	KMSKeyName           string                `json:"kmsKeyName,omitempty"`
+	Schema               *TopicSchema          `json:"schema,omitempty"`
+}
+
+func topicConfigFromConfig(projectID string, cfg pubsub.TopicConfig) TopicConfig {
+	topicCfg := TopicConfig{
+		ID:                cfg.ID(),
+		Name:              cfg.ID(),
+		ProjectID:         projectID,
+		Labels:            cfg.Labels,
+		RetentionDuration: optionalDuration(cfg.RetentionDuration),
+		KMSKeyName:        cfg.KMSKeyName,
+		Schema:            topicSchemaFromSettings(cfg.SchemaSettings),
+	}
+
+	// Without allowed regions the policy of the organization applies, which is not something the topic holds.
+	if len(cfg.MessageStoragePolicy.AllowedPersistenceRegions) > 0 {
+		topicCfg.MessageStoragePolicy = &MessageStoragePolicy{
+			AllowedPersistenceRegions: cfg.MessageStoragePolicy.AllowedPersistenceRegions,
+		}
+	}
+
+	return topicCfg
+}
+
+// updateTopicRequest holds the topic settings to change, settings which are left out remain unchanged. Labels replace
+// the current labels. A retention duration of 0s clears the retention of the topic and a message storage policy
+// without regions reverts to the policy of the organization.
+type updateTopicRequest struct {
+	Labels               map[string]string     `json:"labels"`
+	RetentionDuration    *Duration             `json:"retentionDuration"`
+	MessageStoragePolicy *MessageStoragePolicy `json:"messageStoragePolicy"`
+}
+
+func (req updateTopicRequest) validate() error {
+	if req.Labels == nil && req.RetentionDuration == nil && req.MessageStoragePolicy == nil {
+		return errors.New("nothing to update")
+	}
+
+	if d := req.RetentionDuration; d != nil && *d != 0 &&
+		(time.Duration(*d) < minRetentionDuration || time.Duration(*d) > maxRetentionDuration) {
+		return errors.Errorf(
+			"retention duration must be between %s and %s, or 0s to clear it",
+			minRetentionDuration,
+			maxRetentionDuration,
+		)
+	}
+
+	if req.Labels != nil {
+		err := validateLabels(req.Labels)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// topicConfigToUpdate converts the request to the changes to make to the deployed topic.
+func (req updateTopicRequest) topicConfigToUpdate(deployed pubsub.TopicConfig) pubsub.TopicConfigToUpdate {
+	var update pubsub.TopicConfigToUpdate
+
+	if req.Labels != nil {
+		update.Labels = withReservedLabels(req.Labels, deployed.Labels)
+	}
+
+	if req.RetentionDuration != nil {
+		// Pub/Sub clears the retention duration when it is updated to a negative value.
+		update.RetentionDuration = time.Duration(*req.RetentionDuration)
+		if *req.RetentionDuration == 0 {
+			update.RetentionDuration = time.Duration(-1)
+		}
+	}
+
+	if req.MessageStoragePolicy != nil {
+		update.MessageStoragePolicy = &pubsub.MessageStoragePolicy{
+			AllowedPersistenceRegions: req.MessageStoragePolicy.AllowedPersistenceRegions,
+		}
+	}
+
+	return update
+}
+
 type Topics struct {
-	Topics []Topic `yaml:"topics" json:"topics"`
+	Schemas []Schema `yaml:"schemas" json:"schemas"`
+	Topics  []Topic  `yaml:"topics"  json:"topics"`
 }
 
 func (ts Topics) ProjectIDs() []string {
-	projectIDs := make([]string, len(ts.Topics))
+	projectIDs := make([]string, 0, len(ts.Schemas)+len(ts.Topics))
 
-	for i, topic := range ts.Topics {
-		projectIDs[i] = topic.ProjectID
+	for _, schema := range ts.Schemas {
+		projectIDs = append(projectIDs, schema.ProjectID)
+	}
+
+	for _, topic := range ts.Topics {
+		projectIDs = append(projectIDs, topic.ProjectID)
 	}
 
 	return deduplicateStrings(projectIDs)
@@ -73,6 +304,45 @@ func (ts Topics) Payloads() map[string][]MessagePayload {
 	return payloads
 }
 
+func (t Topic) validate() error {
+	if t.Labels != nil {
+		err := validateLabels(t.Labels)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.Schema != nil {
+		err := t.Schema.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.Protobuf != nil {
+		if t.Schema != nil {
+			return errors.New("protobuf can only be declared for topics without a schema")
+		}
+
+		err := t.Protobuf.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	subNames := make(map[string]bool)
+
+	for _, sub := range t.Subscriptions {
+		if subNames[sub.Name] {
+			return errors.Errorf("subscription %q defined more than once", sub.Name)
+		}
+
+		subNames[sub.Name] = true
+	}
+
+	return nil
+}
+
 func parseTopics(yamlFile io.Reader) (Topics, error) {
 	var topics Topics
 	err := yaml.NewDecoder(yamlFile).Decode(&topics)
@@ -80,6 +350,27 @@ func parseTopics(yamlFile io.Reader) (Topics, error) {
 		return Topics{}, errors.Wrap(err, "could not parse topics")
 	}
 
+	schemaKeys := make(map[string]bool)
+	for _, schema := range topics.Schemas {
+		err = schema.validate()
+		if err != nil {
+			return Topics{}, errors.Wrapf(err, "invalid schema in project %q", schema.ProjectID)
+		}
+
+		if schemaKeys[schema.Key()] {
+			return Topics{}, errors.Errorf("schema %q in project %q defined more than once", schema.Name, schema.ProjectID)
+		}
+
+		schemaKeys[schema.Key()] = true
+	}
+
+	for _, topic := range topics.Topics {
+		err = topic.validate()
+		if err != nil {
+			return Topics{}, errors.Wrapf(err, "invalid topic %q in project %q", topic.Name, topic.ProjectID)
+		}
+	}
+
 	return topics, nil
 }
 
@@ -88,8 +379,10 @@ func createSubscription(
 	client *pubsub.Client,
 	projectID string,
 	topicName string,
-	subscriptionName string,
+	subscriptionCfg TopicSubscription,
 ) error {
+	subscriptionName := subscriptionCfg.Name
+
 	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(timeoutSubscriptionCreation))
 	defer func() {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -108,9 +401,10 @@ func createSubscription(
 
 	topic := client.Topic(topicName)
 
-	_, err := client.CreateSubscription(ctx, subscriptionName, pubsub.SubscriptionConfig{
-		Topic: topic,
-	})
+	cfg := subscriptionCfg.subscriptionConfig(client, topic)
+	cfg.Labels = withManagedLabel(cfg.Labels)
+
+	_, err := client.CreateSubscription(ctx, subscriptionName, cfg)
 	if status.Code(err) == codes.AlreadyExists {
 		logWithPrefix(
 			"subscription: already exists: %q for topic %q in project %q",
@@ -147,7 +441,19 @@ func createTopic(ctx context.Context, client *pubsub.Client, topicCfg Topic) err
 
 	logWithPrefix("topic: creating: %q in project %q", topicCfg.Name, topicCfg.ProjectID)
 
-	_, err := client.CreateTopic(dlctx, topicCfg.Name)
+	cfg := &pubsub.TopicConfig{
+		Labels: withManagedLabel(topicCfg.Labels),
+	}
+	if topicCfg.Schema != nil {
+		schemaSettings, err := topicCfg.Schema.schemaSettings(topicCfg.ProjectID)
+		if err != nil {
+			return errors.Wrapf(err, "topics: could not create %q in project %q", topicCfg.Name, topicCfg.ProjectID)
+		}
+
+		cfg.SchemaSettings = schemaSettings
+	}
+
+	_, err := client.CreateTopicWithConfig(dlctx, topicCfg.Name, cfg)
 	if status.Code(err) == codes.AlreadyExists {
 		logWithPrefix("topic: already exists: %q in project %q", topicCfg.Name, topicCfg.ProjectID)
 		goto CreateSubscriptions
@@ -160,11 +466,11 @@ func createTopic(ctx context.Context, client *pubsub.Client, topicCfg Topic) err
 
 CreateSubscriptions:
 	sg := errgroup.Group{}
-	for _, sn := range topicCfg.Subscriptions {
-		subName := sn
+	for _, sc := range topicCfg.Subscriptions {
+		subCfg := sc
 
 		sg.Go(func() error {
-			return createSubscription(ctx, client, topicCfg.ProjectID, topicCfg.Name, subName)
+			return createSubscription(ctx, client, topicCfg.ProjectID, topicCfg.Name, subCfg)
 		})
 	}
 	err = sg.Wait()
@@ -0,0 +1,121 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateLabels(t *testing.T) {
+	tooMany := make(map[string]string, maxLabels+1)
+	for i := 0; i <= maxLabels; i++ {
+		tooMany[fmt.Sprintf("label-%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{name: "no labels", labels: nil},
+		{name: "valid", labels: map[string]string{"team": "payments", "env_2": "prod-eu", "empty": ""}},
+		{name: "too many", labels: tooMany, wantErr: true},
+		{name: "key starting with digit", labels: map[string]string{"2team": "a"}, wantErr: true},
+		{name: "empty key", labels: map[string]string{"": "a"}, wantErr: true},
+		{name: "uppercase key", labels: map[string]string{"Team": "a"}, wantErr: true},
+		{name: "key too long", labels: map[string]string{strings.Repeat("a", 64): "a"}, wantErr: true},
+		{name: "reserved prefix", labels: map[string]string{labelPrefixReserved + "managed": "true"}, wantErr: true},
+		{name: "uppercase value", labels: map[string]string{"team": "Payments"}, wantErr: true},
+		{name: "value with space", labels: map[string]string{"team": "pay ments"}, wantErr: true},
+		{name: "value too long", labels: map[string]string{"team": strings.Repeat("a", 64)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLabels(tt.labels)
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error, got none")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTopicSubscriptionUnmarshalYAML(t *testing.T) {
+	ordering := true
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    TopicSubscription
+		wantErr bool
+	}{
+		{
+			name: "name only",
+			yaml: "my-sub",
+			want: TopicSubscription{Name: "my-sub"},
+		},
+		{
+			name: "object",
+			yaml: "name: my-sub\nackDeadline: 30s\nenableMessageOrdering: true\nlabels:\n  team: payments",
+			want: TopicSubscription{
+				Name: "my-sub",
+				SubscriptionSettings: SubscriptionSettings{
+					AckDeadline:           Duration(time.Second * 30),
+					EnableMessageOrdering: &ordering,
+					Labels:                map[string]string{"team": "payments"},
+				},
+			},
+		},
+		{
+			name:    "object without name",
+			yaml:    "ackDeadline: 30s",
+			wantErr: true,
+		},
+		{
+			name:    "invalid setting",
+			yaml:    "name: my-sub\nackDeadline: soon",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TopicSubscription
+			err := yaml.Unmarshal([]byte(tt.yaml), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -14,6 +14,14 @@
 
 package pubsubui
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
 func filterEmptyStrings(strs []string) []string {
 	filtered := make([]string, 0)
 
@@ -42,3 +50,59 @@ func deduplicateStrings(strs []string) []string {
 
 	return strings
 }
+
+func stringMapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		bv, ok := b[k]
+		if !ok || bv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Duration is a time.Duration which is represented as a human-readable string (e.g. "1m30s") in JSON and YAML.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(bts []byte) error {
+	var str string
+	err := json.Unmarshal(bts, &str)
+	if err != nil {
+		return errors.Wrap(err, "duration must be a string")
+	}
+
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return errors.Wrapf(err, "invalid duration %q", str)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var str string
+	err := value.Decode(&str)
+	if err != nil {
+		return errors.Wrap(err, "duration must be a string")
+	}
+
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return errors.Wrapf(err, "invalid duration %q", str)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
@@ -0,0 +1,498 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	wsPullInterval = time.Second
+	// wsPullMaxBackoff caps the time between retries of pulls which failed with a transient error, starting at the pull
+	// interval and doubling on every consecutive failure.
+	wsPullMaxBackoff = time.Second * 30
+)
+
+const wsDefaultMaxOutstanding = 10
+
+const (
+	wsCommandAck            = "ack"
+	wsCommandNack           = "nack"
+	wsCommandExtend         = "extend"
+	wsCommandPause          = "pause"
+	wsCommandResume         = "resume"
+	wsCommandMaxOutstanding = "maxOutstanding"
+)
+
+const (
+	wsEventTypeMessage = "message"
+	wsEventTypeState   = "state"
+	wsEventTypeError   = "error"
+)
+
+// The WebSocket endpoints let clients consume messages by acking them, so they can only be used from the same origin,
+// which is what the upgrader checks by default.
+var wsUpgrader = websocket.Upgrader{}
+
+// wsCommand is sent by the client. Ack, nack and extend refer to outstanding messages by ID, extend sets their ack
+// deadline to Deadline. Pause and resume stop and restart pulling, maxOutstanding limits the number of messages which
+// are delivered to the client without having been acked or nacked.
+type wsCommand struct {
+	Command        string   `json:"command"`
+	IDs            []string `json:"ids,omitempty"`
+	Deadline       Duration `json:"deadline,omitempty"`
+	MaxOutstanding int      `json:"maxOutstanding,omitempty"`
+}
+
+func (cmd wsCommand) validate() error {
+	switch cmd.Command {
+	case wsCommandAck, wsCommandNack:
+		if len(cmd.IDs) == 0 {
+			return errors.Errorf("command %q requires message IDs", cmd.Command)
+		}
+	case wsCommandExtend:
+		if len(cmd.IDs) == 0 {
+			return errors.Errorf("command %q requires message IDs", cmd.Command)
+		}
+		if cmd.Deadline <= 0 || time.Duration(cmd.Deadline) > maxAckDeadline {
+			return errors.Errorf("command %q requires a deadline between 1s and %s", cmd.Command, maxAckDeadline)
+		}
+	case wsCommandPause, wsCommandResume:
+	case wsCommandMaxOutstanding:
+		if cmd.MaxOutstanding <= 0 {
+			return errors.Errorf("command %q requires a positive maximum", cmd.Command)
+		}
+	default:
+		return errors.Errorf("unsupported command %q", cmd.Command)
+	}
+
+	return nil
+}
+
+type wsState struct {
+	Paused         bool     `json:"paused"`
+	MaxOutstanding int      `json:"maxOutstanding"`
+	Outstanding    []string `json:"outstanding"`
+}
+
+// wsEvent is sent by the server. Every command is answered with either the resulting state or an error.
+type wsEvent struct {
+	Type    string         `json:"type"`
+	Message *PubSubMessage `json:"message,omitempty"`
+	Command string         `json:"command,omitempty"`
+	IDs     []string       `json:"ids,omitempty"`
+	State   *wsState       `json:"state,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// wsSession pulls messages from a subscription on behalf of a WebSocket client. Nothing is acked, nacked or extended
+// unless the client says so, which means messages are redelivered by Pub/Sub once their ack deadline expires.
+type wsSession struct {
+	conn         *websocket.Conn
+	subscriber   *vkit.SubscriberClient
+	subscription string
+	decoder      messageDecoder
+
+	writeMu sync.Mutex
+
+	mu             sync.Mutex
+	paused         bool
+	maxOutstanding int
+	outstanding    map[string]string
+	wake           chan struct{}
+}
+
+func newWSSession(
+	conn *websocket.Conn,
+	subscriber *vkit.SubscriberClient,
+	subscription string,
+	decoder messageDecoder,
+) *wsSession {
+	return &wsSession{
+		conn:           conn,
+		subscriber:     subscriber,
+		subscription:   subscription,
+		decoder:        decoder,
+		maxOutstanding: wsDefaultMaxOutstanding,
+		outstanding:    make(map[string]string),
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+func (s *wsSession) send(event wsEvent) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.conn.WriteJSON(event)
+}
+
+func (s *wsSession) state() *wsState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outstanding := make([]string, 0, len(s.outstanding))
+	for id := range s.outstanding {
+		outstanding = append(outstanding, id)
+	}
+
+	return &wsState{
+		Paused:         s.paused,
+		MaxOutstanding: s.maxOutstanding,
+		Outstanding:    outstanding,
+	}
+}
+
+// available returns how many messages can be pulled without exceeding the maximum number of outstanding messages.
+func (s *wsSession) available() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.paused || len(s.outstanding) >= s.maxOutstanding {
+		return 0
+	}
+
+	return s.maxOutstanding - len(s.outstanding)
+}
+
+// wakeUp makes the pull loop check again whether it can pull, after the client freed up room or resumed.
+func (s *wsSession) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ackIDs looks up the ack IDs of the given outstanding messages, which are no longer outstanding afterwards when
+// remove is set.
+func (s *wsSession) ackIDs(ids []string, remove bool) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ackIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		ackID, ok := s.outstanding[id]
+		if !ok {
+			return nil, errors.Errorf("message %q is not outstanding", id)
+		}
+
+		ackIDs = append(ackIDs, ackID)
+	}
+
+	if remove {
+		for _, id := range ids {
+			delete(s.outstanding, id)
+		}
+	}
+
+	return ackIDs, nil
+}
+
+// isRetryablePullError tells whether a pull failed because of a transient error, in which case it may succeed when
+// tried again.
+func isRetryablePullError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *wsSession) pull(ctx context.Context) error {
+	backoff := wsPullInterval
+
+	for {
+		n := s.available()
+		if n == 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-s.wake:
+				continue
+			}
+		}
+
+		res, err := s.subscriber.Pull(ctx, &pb.PullRequest{
+			Subscription: s.subscription,
+			MaxMessages:  int32(n),
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if !isRetryablePullError(err) {
+				return errors.Wrap(err, "could not pull messages")
+			}
+
+			logWithPrefix("websocket: could not pull messages, retrying in %s: %+v", backoff, err)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > wsPullMaxBackoff {
+				backoff = wsPullMaxBackoff
+			}
+
+			continue
+		}
+
+		backoff = wsPullInterval
+
+		if len(res.ReceivedMessages) == 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-s.wake:
+			case <-time.After(wsPullInterval):
+			}
+
+			continue
+		}
+
+		// The client may have paused or lowered the maximum while pulling, in which case the excess messages are
+		// nacked rather than delivered.
+		received := res.ReceivedMessages
+		if n := s.available(); n < len(received) {
+			s.nack(ctx, received[n:])
+			received = received[:n]
+		}
+
+		for _, rm := range received {
+			err := s.deliver(ctx, rm)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *wsSession) nack(ctx context.Context, rms []*pb.ReceivedMessage) {
+	ackIDs := make([]string, 0, len(rms))
+	for _, rm := range rms {
+		ackIDs = append(ackIDs, rm.GetAckId())
+	}
+
+	err := s.subscriber.ModifyAckDeadline(ctx, &pb.ModifyAckDeadlineRequest{
+		Subscription:       s.subscription,
+		AckIds:             ackIDs,
+		AckDeadlineSeconds: 0,
+	})
+	if err != nil {
+		logWithPrefix("websocket: could not nack messages: %+v", err)
+	}
+}
+
+// deliver sends a message to the client, after which it is outstanding. A message which cannot be converted is nacked
+// right away and reported to the client instead.
+func (s *wsSession) deliver(ctx context.Context, rm *pb.ReceivedMessage) error {
+	msg := &pubsub.Message{
+		ID:          rm.GetMessage().GetMessageId(),
+		Data:        rm.GetMessage().GetData(),
+		Attributes:  rm.GetMessage().GetAttributes(),
+		OrderingKey: rm.GetMessage().GetOrderingKey(),
+		PublishTime: rm.GetMessage().GetPublishTime().AsTime(),
+	}
+	if rm.GetDeliveryAttempt() > 0 {
+		attempt := int(rm.GetDeliveryAttempt())
+		msg.DeliveryAttempt = &attempt
+	}
+
+	psMsg, err := pubSubMessageFromMessage(msg, s.decoder)
+	if err != nil {
+		logWithPrefix("websocket: could not convert pubsub message: %+v", err)
+
+		s.nack(ctx, []*pb.ReceivedMessage{rm})
+
+		err = s.send(wsEvent{
+			Type:  wsEventTypeError,
+			IDs:   []string{msg.ID},
+			Error: errors.Wrap(err, "could not convert message").Error(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "could not send conversion error")
+		}
+
+		return nil
+	}
+
+	// A redelivered message replaces the earlier delivery, whose ack ID is no longer valid. The message is outstanding
+	// before it is sent, so the client cannot ack it before it is known.
+	s.mu.Lock()
+	s.outstanding[msg.ID] = rm.GetAckId()
+	s.mu.Unlock()
+
+	err = s.send(wsEvent{
+		Type:    wsEventTypeMessage,
+		Message: &psMsg,
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not send message")
+	}
+
+	return nil
+}
+
+func (s *wsSession) handle(ctx context.Context, cmd wsCommand) error {
+	err := cmd.validate()
+	if err != nil {
+		return err
+	}
+
+	switch cmd.Command {
+	case wsCommandAck:
+		ackIDs, err := s.ackIDs(cmd.IDs, true)
+		if err != nil {
+			return err
+		}
+
+		err = s.subscriber.Acknowledge(ctx, &pb.AcknowledgeRequest{
+			Subscription: s.subscription,
+			AckIds:       ackIDs,
+		})
+		if err != nil {
+			return errors.Wrap(err, "could not ack messages")
+		}
+	case wsCommandNack, wsCommandExtend:
+		remove := cmd.Command == wsCommandNack
+
+		ackIDs, err := s.ackIDs(cmd.IDs, remove)
+		if err != nil {
+			return err
+		}
+
+		// Nacking is setting the ack deadline to zero, so the message is redelivered right away.
+		deadline := time.Duration(0)
+		if !remove {
+			deadline = time.Duration(cmd.Deadline)
+		}
+
+		err = s.subscriber.ModifyAckDeadline(ctx, &pb.ModifyAckDeadlineRequest{
+			Subscription:       s.subscription,
+			AckIds:             ackIDs,
+			AckDeadlineSeconds: int32(deadline.Seconds()),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not %s messages", cmd.Command)
+		}
+	case wsCommandPause, wsCommandResume:
+		s.mu.Lock()
+		s.paused = cmd.Command == wsCommandPause
+		s.mu.Unlock()
+	case wsCommandMaxOutstanding:
+		s.mu.Lock()
+		s.maxOutstanding = cmd.MaxOutstanding
+		s.mu.Unlock()
+	}
+
+	s.wakeUp()
+
+	return nil
+}
+
+// read handles the commands of the client until it disconnects.
+func (s *wsSession) read(ctx context.Context) error {
+	for {
+		var cmd wsCommand
+		err := s.conn.ReadJSON(&cmd)
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+
+			return errors.Wrap(err, "could not read command")
+		}
+
+		event := wsEvent{
+			Type:    wsEventTypeState,
+			Command: cmd.Command,
+			IDs:     cmd.IDs,
+		}
+
+		err = s.handle(ctx, cmd)
+		if err != nil {
+			event.Type = wsEventTypeError
+			event.Error = err.Error()
+		} else {
+			event.State = s.state()
+		}
+
+		err = s.send(event)
+		if err != nil {
+			return errors.Wrap(err, "could not send command result")
+		}
+	}
+}
+
+// release nacks the messages which are still outstanding, so they are redelivered right away instead of after their
+// ack deadline expires.
+func (s *wsSession) release() {
+	s.mu.Lock()
+	rms := make([]*pb.ReceivedMessage, 0, len(s.outstanding))
+	for _, ackID := range s.outstanding {
+		rms = append(rms, &pb.ReceivedMessage{AckId: ackID})
+	}
+	s.mu.Unlock()
+
+	if len(rms) == 0 {
+		return
+	}
+
+	s.nack(context.Background(), rms)
+}
+
+// run pulls and handles commands until either the client disconnects or pulling fails.
+func (s *wsSession) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.release()
+
+	errCh := make(chan error, 2)
+
+	go func() {
+		errCh <- s.pull(ctx)
+	}()
+	go func() {
+		errCh <- s.read(ctx)
+	}()
+
+	err := <-errCh
+	if err != nil {
+		logWithPrefix("websocket: %+v", err)
+
+		s.send(wsEvent{
+			Type:  wsEventTypeError,
+			Error: err.Error(),
+		})
+	}
+
+	// Closing the connection also stops reading, pulling stops once the context is cancelled.
+	cancel()
+	s.conn.Close()
+	<-errCh
+}
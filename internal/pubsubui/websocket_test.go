@@ -0,0 +1,139 @@
+// Copyright 2022 Dennis Vis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/gorilla/websocket"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestWSSessionRetriesTransientPullErrors(t *testing.T) {
+	ctx := context.Background()
+
+	pullInterval := wsPullInterval
+	wsPullInterval = time.Millisecond * 10
+	t.Cleanup(func() {
+		wsPullInterval = pullInterval
+	})
+
+	psSrv := pstest.NewServer()
+	t.Cleanup(func() {
+		psSrv.Close()
+	})
+
+	// The first pull fails with an error the subscriber client does not retry by itself.
+	var pulls int32
+	failFirstPull := func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if method == "/google.pubsub.v1.Subscriber/Pull" && atomic.AddInt32(&pulls, 1) == 1 {
+			return status.Error(codes.ResourceExhausted, "too many pulls")
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	conn, err := grpc.Dial(
+		psSrv.Addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(failFirstPull),
+	)
+	if err != nil {
+		t.Fatalf("could not dial fake Pub/Sub server: %v", err)
+	}
+
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	subscriber, err := vkit.NewSubscriberClient(ctx, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("could not create subscriber client: %v", err)
+	}
+	t.Cleanup(func() {
+		subscriber.Close()
+	})
+
+	topic, err := client.CreateTopic(ctx, "topic")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+	defer topic.Stop()
+
+	sub, err := client.CreateSubscription(ctx, "subscription", pubsub.SubscriptionConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("could not create subscription: %v", err)
+	}
+
+	id, err := topic.Publish(ctx, &pubsub.Message{Data: []byte(`{"a":1}`)}).Get(ctx)
+	if err != nil {
+		t.Fatalf("could not publish message: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wsConn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		newWSSession(wsConn, subscriber, sub.String(), jsonDecoder{}).run(r.Context())
+	}))
+	t.Cleanup(srv.Close)
+
+	wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("could not connect to WebSocket: %v", err)
+	}
+	defer wsConn.Close()
+
+	err = wsConn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	if err != nil {
+		t.Fatalf("could not set read deadline: %v", err)
+	}
+
+	var event wsEvent
+	err = wsConn.ReadJSON(&event)
+	if err != nil {
+		t.Fatalf("could not read event: %v", err)
+	}
+
+	if event.Type != wsEventTypeMessage || event.Message == nil || event.Message.ID != id {
+		t.Errorf("expected message %q to be delivered after retrying, got %+v", id, event)
+	}
+}
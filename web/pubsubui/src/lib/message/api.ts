@@ -19,10 +19,18 @@ export const api = {
     onOpen: () => void,
     onMessage: (msg: string) => void,
     onError: (err: string) => void,
+    onReconnecting: () => void,
   ): () => void {
     const source = new EventSource(`/api/projects/${projectId}/topics/${topicId}`)
 
     source.onerror = () => {
+      // The EventSource reconnects by itself after a network blip, sending the ID of the last message it received so
+      // the server can replay the messages we missed. Only when it gives up is the subscription closed.
+      if (source.readyState === EventSource.CONNECTING) {
+        onReconnecting()
+        return
+      }
+
       // Sadly, we don't get any descriptive error from the EventSource and therefore have to guess what happened.
       onError('Subscription failed, do you have sufficient permissions?')
     }
@@ -33,6 +41,11 @@ export const api = {
       onMessage(message.data)
     }
 
+    // The server no longer knows the last message we received, so the messages we missed while reconnecting are lost.
+    source.addEventListener('reset', () => {
+      console.warn('reconnected to subscription, but the messages sent in the meantime could not be replayed')
+    })
+
     return () => {
       source.close()
     }
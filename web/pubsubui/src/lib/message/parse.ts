@@ -12,7 +12,23 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-import { PubSubMessage } from './types'
+import { DeadLetterSource, PubSubMessage } from './types'
+
+function jsonToDeadLetterSource(json: any): DeadLetterSource | undefined {
+  if (!json) {
+    return undefined
+  }
+  if (typeof(json) !== 'object') {
+    throw new Error('pubsub message JSON dead letter source not an object')
+  }
+
+  return new DeadLetterSource(
+    json.subscription,
+    json.projectId,
+    json.deliveryCount,
+    json.topicPublishTime,
+  )
+}
 
 export function jsonToPubSubMessage(json: any): PubSubMessage {
   if (typeof(json) !== 'object') {
@@ -27,11 +43,21 @@ export function jsonToPubSubMessage(json: any): PubSubMessage {
   if (!!json.attributes && typeof(json.attributes) !== 'object') {
     throw new Error('pubsub message JSON attributes not an object')
   }
+  if (json.encoding !== 'json' && json.encoding !== 'text' && json.encoding !== 'base64') {
+    throw new Error('pubsub message JSON encoding not "json", "text" or "base64"')
+  }
+  if (json.deliveryAttempt !== undefined && typeof(json.deliveryAttempt) !== 'number') {
+    throw new Error('pubsub message JSON delivery attempt not a number')
+  }
 
   return new PubSubMessage(
     json.id,
     json.data,
+    json.encoding,
     json.publishTime,
     json.attributes,
+    json.decodeError,
+    json.deliveryAttempt,
+    jsonToDeadLetterSource(json.deadLetterSource),
   )
 }
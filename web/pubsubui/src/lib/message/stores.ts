@@ -42,9 +42,14 @@ function createMessages() {
       },
       message => update(s => {
         const newMessage = jsonToPubSubMessage(JSON.parse(message))
+        if (s.messages.some(m => m.id === newMessage.id)) {
+          return s
+        }
+
         return new MessagesState(s.connecting, s.open, s.topic, [newMessage, ...s.messages])
       }),
       err => update(() => new MessagesState(false, false, '', [], err)),
+      () => update(s => new MessagesState(true, false, s.topic, s.messages)),
     )
   }
 
@@ -12,14 +12,29 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+export type MessageEncoding = 'json' | 'text' | 'base64'
+
+export class DeadLetterSource {
+  constructor(
+    readonly subscription: string | undefined,
+    readonly projectId: string | undefined,
+    readonly deliveryCount: number | undefined,
+    readonly topicPublishTime: string | undefined,
+  ) {}
+}
+
 export class PubSubMessage {
   readonly publishDate: Date
 
   constructor(
     readonly id: string,
     readonly data: any,
+    readonly encoding: MessageEncoding,
     readonly publishTime: string,
     readonly attributes: { [key: string]: string } | undefined,
+    readonly decodeError: string | undefined = undefined,
+    readonly deliveryAttempt: number | undefined = undefined,
+    readonly deadLetterSource: DeadLetterSource | undefined = undefined,
   ) {
     this.publishDate = new Date(publishTime)
   }
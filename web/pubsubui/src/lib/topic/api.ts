@@ -13,10 +13,20 @@
 // limitations under the License.
 
 import { jsonToCreateTopicResponse, jsonToListTopicsResponse, jsonToPublishMessageResponse } from "./parse"
-import type { CreateTopicResponse, ListTopicsResponse, PublishMessageResponse } from "./types"
+import type {
+  CreateTopicResponse,
+  ListTopicsResponse,
+  PublishMessageResponse,
+  SubscriptionsAction,
+  TopicFilter,
+} from "./types"
 
 export const api = {
-  async createTopic(projectId: string, topicName: string): Promise<CreateTopicResponse> {
+  async createTopic(
+    projectId: string,
+    topicName: string,
+    labels?: Record<string, string>,
+  ): Promise<CreateTopicResponse> {
     try {
       const res = await fetch('/api/projects/' + projectId + '/topics', {
         method: 'POST',
@@ -25,6 +35,7 @@ export const api = {
         },
         body: JSON.stringify({
           name: topicName,
+          labels,
         }),
       })
       if (res.status >= 400) {
@@ -39,9 +50,29 @@ export const api = {
     }
   },
 
-  async listTopics(projectId: string, page: number, pageSize: number): Promise<ListTopicsResponse> {
+  async listTopics(
+    projectId: string,
+    page: number,
+    pageSize: number,
+    filter: TopicFilter = {},
+  ): Promise<ListTopicsResponse> {
     try {
-      const res = await fetch(`/api/projects/${projectId}/topics?page=${page}&pageSize=${pageSize}`)
+      const params = new URLSearchParams({ page: `${page}`, pageSize: `${pageSize}` })
+      if (filter.labels) {
+        params.set('labels', filter.labels)
+      }
+      if (filter.q) {
+        params.set('q', filter.q)
+        params.set('regex', `${!!filter.regex}`)
+      }
+      if (filter.prefix) {
+        params.set('prefix', filter.prefix)
+      }
+      if (filter.sort) {
+        params.set('sort', filter.sort)
+      }
+
+      const res = await fetch(`/api/projects/${projectId}/topics?${params}`)
       if (res.status >= 400) {
         throw new Error(`could not list topics: ${await res.text()}`)
       }
@@ -54,6 +85,20 @@ export const api = {
     }
   },
 
+  async deleteTopic(projectId: string, topicId: string, subscriptions: SubscriptionsAction): Promise<void> {
+    try {
+      const res = await fetch(`/api/projects/${projectId}/topics/${topicId}?subscriptions=${subscriptions}`, {
+        method: 'DELETE',
+      })
+      if (res.status >= 400) {
+        throw new Error(`could not delete topic: ${await res.text()}`)
+      }
+    } catch (err) {
+      console.error('could not call delete topic endpoint', err)
+      throw err
+    }
+  },
+
   async publishMessage(projectId: string, topicId: string, message: any): Promise<PublishMessageResponse> {
     try {
       const res = await fetch(`/api/projects/${projectId}/topics/${topicId}`, {
@@ -12,7 +12,15 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-import { CreateTopicResponse, ListTopicsResponse, MessagePayload, PublishMessageResponse, Topic } from './types'
+import {
+  CreateTopicResponse,
+  ListTopicsResponse,
+  MatchRange,
+  MessagePayload,
+  PublishMessageResponse,
+  Topic,
+  TopicSchema,
+} from './types'
 
 function jsonToMessagePayload(json: any): MessagePayload {
   if (typeof(json) !== 'object') {
@@ -28,6 +36,31 @@ function jsonToMessagePayload(json: any): MessagePayload {
   return new MessagePayload(json.name, json.payload)
 }
 
+function jsonToTopicSchema(json: any): TopicSchema {
+  if (typeof(json) !== 'object') {
+    throw new Error('topic schema JSON not an object')
+  }
+  if (typeof(json.name) !== 'string') {
+    throw new Error('topic schema JSON did not contain a name string')
+  }
+  if (json.encoding !== 'json' && json.encoding !== 'binary') {
+    throw new Error('topic schema JSON did not contain a valid encoding')
+  }
+
+  return new TopicSchema(json.name, json.encoding)
+}
+
+function jsonToMatchRange(json: any): MatchRange {
+  if (typeof(json) !== 'object') {
+    throw new Error('match range JSON not an object')
+  }
+  if (typeof(json.start) !== 'number' || typeof(json.end) !== 'number') {
+    throw new Error('match range JSON did not contain start and end numbers')
+  }
+
+  return new MatchRange(json.start, json.end)
+}
+
 export function jsonToTopic(json: any): Topic {
   if (typeof(json.id) !== 'string') {
     throw new Error('ID in topic JSON not a string')
@@ -40,12 +73,20 @@ export function jsonToTopic(json: any): Topic {
   }
 
   const payloads = (json.payloads || []).map(jsonToMessagePayload)
+  const schema = json.schema ? jsonToTopicSchema(json.schema) : undefined
+  const matches = (json.matches || []).map(jsonToMatchRange)
+  if (json.labels !== undefined && typeof(json.labels) !== 'object') {
+    throw new Error('labels in topic JSON not an object')
+  }
 
   return new Topic(
     json.id,
     json.name,
     json.projectId,
     payloads,
+    schema,
+    json.labels || {},
+    matches,
   )
 }
 
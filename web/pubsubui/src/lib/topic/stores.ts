@@ -17,6 +17,7 @@ import { activeProject } from '../project/stores'
 import { appWindow } from '../window/stores'
 import { api } from './api'
 import { TopicsState } from './types'
+import type { SubscriptionsAction } from './types'
 
 function createTopics() {
   let totalPages = 1
@@ -99,6 +100,37 @@ function createTopics() {
     }
   }
 
+  async function deleteTopic(projectId: string, topicId: string, subscriptions: SubscriptionsAction) {
+    update(s => {
+      return new TopicsState(
+        true,
+        s.topics,
+        s.page,
+        s.totalPages,
+      )
+    })
+
+    try {
+      await api.deleteTopic(projectId, topicId, subscriptions)
+
+      update(s => new TopicsState(
+        false,
+        s.topics.filter(t => t.id !== topicId),
+        s.page,
+        s.totalPages,
+      ))
+    } catch (err) {
+      console.error('could not delete topic', err)
+      update(s => new TopicsState(
+        false,
+        s.topics,
+        s.page,
+        s.totalPages,
+      ))
+      throw err
+    }
+  }
+
   async function publishMessage(projectId: string, topicId: string, message: string) {
     try {
       await api.publishMessage(projectId, topicId, message)
@@ -158,6 +190,7 @@ function createTopics() {
     prevPage,
     nextPage,
     createTopic,
+    deleteTopic,
     publishMessage,
   }
 }
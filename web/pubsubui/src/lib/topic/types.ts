@@ -19,15 +19,44 @@ export class MessagePayload {
   ) {}
 }
 
+export type SchemaEncoding = 'json' | 'binary'
+
+export class TopicSchema {
+  constructor(
+    readonly name: string,
+    readonly encoding: SchemaEncoding,
+  ) {}
+}
+
+export class MatchRange {
+  constructor(
+    readonly start: number,
+    readonly end: number,
+  ) {}
+}
+
 export class Topic {
   constructor(
     readonly id: string,
     readonly name: string,
     readonly projectId: string,
     readonly payloads: MessagePayload[],
+    readonly schema?: TopicSchema,
+    readonly labels: Record<string, string> = {},
+    readonly matches: MatchRange[] = [],
   ) {}
 }
 
+export type TopicSort = 'name' | '-name' | 'relevance'
+
+export interface TopicFilter {
+  labels?: string
+  q?: string
+  regex?: boolean
+  prefix?: string
+  sort?: TopicSort
+}
+
 export class CreateTopicResponse {
   constructor(
     readonly topic: Topic,
@@ -54,6 +83,8 @@ export class TopicsState {
   ){}
 }
 
+export type SubscriptionsAction = 'keep' | 'delete' | 'detach'
+
 export class PublishMessageResponse {
   constructor(
     readonly projectId: string,